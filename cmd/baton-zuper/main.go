@@ -4,11 +4,13 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/conductorone/baton-sdk/pkg/config"
 	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
 	"github.com/conductorone/baton-sdk/pkg/field"
 	"github.com/conductorone/baton-sdk/pkg/types"
+	"github.com/conductorone/baton-zuper/pkg/client"
 	cfg "github.com/conductorone/baton-zuper/pkg/config"
 	"github.com/conductorone/baton-zuper/pkg/connector"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
@@ -46,12 +48,77 @@ func getConnector(ctx context.Context, zc *cfg.Zuper) (types.ConnectorServer, er
 		return nil, err
 	}
 
-	cb, err := connector.New(ctx, zc.ApiUrl, zc.ApiKey)
+	var verifyTimeout time.Duration
+	if zc.VerifyWrites {
+		verifyTimeout = time.Duration(zc.VerifyTimeout) * time.Second
+	}
+
+	opts := []connector.Option{
+		connector.WithClassificationRules(connector.ClassificationRules{
+			UserTypes:          zc.ContractorUserTypes,
+			EmailDomains:       zc.ContractorEmailDomains,
+			DesignationPattern: zc.ContractorDesignationPattern,
+			EmpCodePrefix:      zc.ContractorEmpCodePrefix,
+		}),
+		connector.WithExpirySweep(zc.ExpirySweep),
+		connector.WithGrantExpiryStore(zc.GrantExpiryStore),
+		connector.WithDryRun(zc.DryRun),
+		connector.WithAuditJournal(zc.AuditJournal),
+		connector.WithWriteVerification(verifyTimeout),
+		connector.WithHTTPTransport(client.TransportOptions{
+			ProxyURL:        zc.HttpsProxy,
+			CABundle:        zc.CaBundle,
+			ClientCert:      zc.ClientCert,
+			ClientKey:       zc.ClientKey,
+			Timeout:         time.Duration(zc.HttpTimeout) * time.Second,
+			ResponseTimeout: time.Duration(zc.HttpResponseTimeout) * time.Second,
+		}),
+		connector.WithRateLimit(client.RateLimitOptions{
+			RequestsPerSecond: float64(zc.RateLimit) / 60,
+			MaxInFlight:       zc.MaxInFlight,
+			QuotaShare:        float64(zc.QuotaShare) / 100,
+		}),
+		connector.WithCircuitBreaker(client.CircuitBreakerOptions{
+			Threshold: zc.CircuitBreakerThreshold,
+			CoolDown:  time.Duration(zc.CircuitBreakerCoolDown) * time.Second,
+		}),
+		connector.WithAssetHosts(zc.AssetHosts),
+	}
+
+	var cb connectorbuilder.ConnectorBuilder
+	var err error
+	if zc.TenantsFile != "" {
+		var tenants []connector.Tenant
+		tenants, err = connector.LoadTenants(zc.TenantsFile, zc.ApiKeyFile, zc.ApiKeyCommand)
+		if err != nil {
+			l.Error("error loading tenants", zap.Error(err))
+			return nil, err
+		}
+		cb, err = connector.NewMultiTenant(ctx, tenants, opts...)
+	} else {
+		apiKey := zc.ApiKey
+		var keySource client.APIKeySource
+		switch {
+		case zc.ApiKeyFile != "":
+			keySource = client.APIKeyFromFile(zc.ApiKeyFile)
+		case zc.ApiKeyCommand != "":
+			keySource = client.APIKeyFromCommand(zc.ApiKeyCommand)
+		}
+		if keySource != nil {
+			apiKey, err = keySource(ctx)
+			if err != nil {
+				l.Error("error loading API key", zap.Error(err))
+				return nil, err
+			}
+		}
+		cb, err = connector.New(ctx, zc.ApiUrl, apiKey,
+			append(opts, connector.WithCompanyName(zc.CompanyName), connector.WithAPIKeySource(keySource))...)
+	}
 	if err != nil {
 		l.Error("error creating connector", zap.Error(err))
 		return nil, err
 	}
-	c, err := connectorbuilder.NewConnector(ctx, cb)
+	c, err := connector.NewServer(ctx, cb, zc.SyncReport)
 	if err != nil {
 		l.Error("error creating connector", zap.Error(err))
 		return nil, err
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+)
+
+// APIKeySource loads the Zuper API key, for example from a file or the output of a command.
+type APIKeySource func(ctx context.Context) (string, error)
+
+// APIKeyFromFile returns an APIKeySource reading the key from the file at path.
+func APIKeyFromFile(path string) APIKeySource {
+	return func(ctx context.Context) (string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read API key file: %w", err)
+		}
+		key := strings.TrimSpace(string(data))
+		if key == "" {
+			return "", fmt.Errorf("API key file %s is empty", path)
+		}
+		return key, nil
+	}
+}
+
+// APIKeyFromCommand returns an APIKeySource running command with the shell and reading the key from its output.
+// The env variables, in the form "KEY=value", are added to the environment of the command.
+func APIKeyFromCommand(command string, env ...string) APIKeySource {
+	return func(ctx context.Context) (string, error) {
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		if len(env) > 0 {
+			cmd.Env = append(os.Environ(), env...)
+		}
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("API key command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		key := strings.TrimSpace(string(out))
+		if key == "" {
+			return "", fmt.Errorf("API key command printed no key")
+		}
+		return key, nil
+	}
+}
+
+// SetAPIKeySource makes the client reload the API key from source whenever Zuper rejects it, so that a rotated
+// key is picked up without a restart.
+func (c *Client) SetAPIKeySource(source APIKeySource) {
+	c.keySource = source
+}
+
+// currentAPIKey returns the API key requests are sent with.
+func (c *Client) currentAPIKey() string {
+	c.keyMu.RLock()
+	defer c.keyMu.RUnlock()
+	return c.apiKey
+}
+
+// refreshAPIKey reloads the API key after Zuper rejected the key. It reports whether requests should be
+// retried with a different key, which is also the case when a concurrent request already reloaded it.
+func (c *Client) refreshAPIKey(ctx context.Context, rejected string) (bool, error) {
+	if c.keySource == nil {
+		return false, nil
+	}
+	c.keyMu.Lock()
+	defer c.keyMu.Unlock()
+	if c.apiKey != rejected {
+		return true, nil
+	}
+	key, err := c.keySource(ctx)
+	if err != nil {
+		return false, err
+	}
+	if key == rejected {
+		return false, nil
+	}
+	c.apiKey = key
+	ctxzap.Extract(ctx).Info("reloaded API key after Zuper rejected it")
+	return true, nil
+}
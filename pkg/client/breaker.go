@@ -0,0 +1,217 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/conductorone/baton-sdk/pkg/metrics"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultCircuitBreakerCoolDown is how long the circuit breaker stays open when no cool-down is configured.
+const DefaultCircuitBreakerCoolDown = 30 * time.Second
+
+// CircuitBreakerOptions configures when the client stops sending requests to an unavailable Zuper.
+type CircuitBreakerOptions struct {
+	// Threshold is the number of consecutive 5xx responses or timeouts that open the breaker. Zero disables it.
+	Threshold int
+	// CoolDown is how long the breaker fails requests fast before letting a single probe through.
+	CoolDown time.Duration
+}
+
+// breakerState is the state of a circuitBreaker. Its value is reported by the circuit breaker state gauge.
+type breakerState int64
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker fails requests fast once Zuper looks unavailable. It opens after a run of consecutive failures,
+// stays open for a cool-down, then half-opens and lets a single probe decide whether to close or open again.
+// A nil circuitBreaker lets every request through.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	coolDown  time.Duration
+	state     breakerState
+	failures  int
+	openedAt  time.Time
+	probing   bool
+
+	stateGauge metrics.Int64Gauge
+	rejected   metrics.Int64Counter
+}
+
+// SetCircuitBreaker makes the client fail fast while Zuper is unavailable, according to opts.
+func (c *Client) SetCircuitBreaker(opts CircuitBreakerOptions) {
+	c.breaker = newCircuitBreaker(opts, c.metrics)
+}
+
+// newCircuitBreaker returns a circuitBreaker configured with opts reporting its state to handler, or nil if opts
+// disable it.
+func newCircuitBreaker(opts CircuitBreakerOptions, handler metrics.Handler) *circuitBreaker {
+	if opts.Threshold <= 0 {
+		return nil
+	}
+	coolDown := opts.CoolDown
+	if coolDown <= 0 {
+		coolDown = DefaultCircuitBreakerCoolDown
+	}
+	if handler == nil {
+		handler = metrics.NewNoOpHandler(context.Background())
+	}
+	return &circuitBreaker{
+		threshold: opts.Threshold,
+		coolDown:  coolDown,
+		stateGauge: handler.Int64Gauge("zuper_circuit_breaker_state",
+			"State of the Zuper circuit breaker: 0 closed, 1 open, 2 half-open", metrics.Dimensionless),
+		rejected: handler.Int64Counter("zuper_circuit_breaker_rejected",
+			"Requests failed fast because the Zuper circuit breaker was open", metrics.Dimensionless),
+	}
+}
+
+// breakerCall is a request the breaker let through. Exactly one of done or cancel must be called once the request
+// is over. A nil breakerCall, returned when the breaker is disabled, ignores both.
+type breakerCall struct {
+	breaker *circuitBreaker
+	ctx     context.Context
+	probe   bool
+}
+
+// done records the outcome of the request sent to Zuper.
+func (c *breakerCall) done(resp *http.Response, err error) {
+	if c == nil {
+		return
+	}
+	c.breaker.record(c.ctx, c.probe, breakerFailure(c.ctx, resp, err), time.Now())
+}
+
+// cancel gives up a request that was never sent. It frees the probe slot if the request was the half-open probe,
+// so the next request can probe instead, and otherwise leaves the breaker as it is.
+func (c *breakerCall) cancel() {
+	if c == nil || !c.probe {
+		return
+	}
+	c.breaker.mu.Lock()
+	defer c.breaker.mu.Unlock()
+	c.breaker.probing = false
+}
+
+// allow reports whether a request may be sent at now, failing fast with a "Zuper unavailable" error while the
+// breaker is open. The returned call must be finished with the outcome of the request, or cancelled if the request
+// is never sent.
+func (b *circuitBreaker) allow(ctx context.Context, now time.Time) (*breakerCall, error) {
+	if b == nil {
+		return nil, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	probe := false
+	switch b.state {
+	case breakerOpen:
+		retryAt := b.openedAt.Add(b.coolDown)
+		if now.Before(retryAt) {
+			b.rejected.Add(ctx, 1, nil)
+			return nil, status.Errorf(codes.Unavailable,
+				"Zuper unavailable: %d consecutive requests failed, retrying in %s",
+				b.failures, retryAt.Sub(now).Round(time.Second))
+		}
+		b.transition(ctx, breakerHalfOpen)
+		fallthrough
+	case breakerHalfOpen:
+		if b.probing {
+			b.rejected.Add(ctx, 1, nil)
+			return nil, status.Error(codes.Unavailable, "Zuper unavailable: waiting for a probe request to succeed")
+		}
+		b.probing = true
+		probe = true
+	}
+	return &breakerCall{breaker: b, ctx: ctx, probe: probe}, nil
+}
+
+// record updates the breaker with the outcome of a request, which was the half-open probe if probe is set.
+func (b *circuitBreaker) record(ctx context.Context, probe bool, failed bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.probing = false
+	}
+	if !failed {
+		b.failures = 0
+		if b.state != breakerClosed {
+			b.transition(ctx, breakerClosed)
+		}
+		return
+	}
+
+	b.failures++
+	// Requests sent before the breaker opened may still fail; only the probe reopens it.
+	switch {
+	case b.state == breakerHalfOpen && probe:
+		b.openedAt = now
+		b.transition(ctx, breakerOpen)
+	case b.state == breakerClosed && b.failures >= b.threshold:
+		b.openedAt = now
+		b.transition(ctx, breakerOpen)
+	}
+}
+
+// transition moves the breaker to state, logging the change and reporting it to the state gauge.
+func (b *circuitBreaker) transition(ctx context.Context, state breakerState) {
+	l := ctxzap.Extract(ctx)
+	fields := []zap.Field{
+		zap.String("from", b.state.String()),
+		zap.String("to", state.String()),
+		zap.Int("consecutive_failures", b.failures),
+	}
+	switch state {
+	case breakerOpen:
+		l.Warn("Zuper circuit breaker opened, failing requests fast",
+			append(fields, zap.Duration("cool_down", b.coolDown))...)
+	case breakerHalfOpen:
+		l.Info("Zuper circuit breaker half-open, sending a probe request", fields...)
+	default:
+		l.Info("Zuper circuit breaker closed", fields...)
+	}
+	b.state = state
+	b.stateGauge.Observe(ctx, int64(state), nil)
+}
+
+// breakerFailure reports whether the outcome of a request suggests Zuper is unavailable: a 5xx response, or a
+// timeout or connection failure that was not caused by the caller giving up.
+func breakerFailure(ctx context.Context, resp *http.Response, err error) bool {
+	if resp != nil {
+		return resp.StatusCode >= http.StatusInternalServerError
+	}
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.DeadlineExceeded, codes.Unavailable:
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"sync"
+)
+
+// DefaultAssetCacheBytes is the most asset data, in bytes, kept in memory.
+const DefaultAssetCacheBytes = 64 << 20
+
+// cachedAsset holds a downloaded asset and its content type.
+type cachedAsset struct {
+	contentType string
+	data        []byte
+}
+
+// assetCache is an in-memory cache of downloaded assets keyed by URL, bounded by the total size of their data.
+type assetCache struct {
+	mu       sync.Mutex
+	maxBytes int
+	size     int
+	order    []string
+	entries  map[string]*cachedAsset
+}
+
+// newAssetCache creates an assetCache holding at most maxBytes of asset data.
+func newAssetCache(maxBytes int) *assetCache {
+	return &assetCache{
+		maxBytes: maxBytes,
+		entries:  make(map[string]*cachedAsset),
+	}
+}
+
+// get returns the cached asset for a key, if present.
+func (a *assetCache) get(key string) (*cachedAsset, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	entry, ok := a.entries[key]
+	return entry, ok
+}
+
+// set stores an asset, evicting the oldest entries until the cache fits within its byte limit. An asset larger
+// than the whole cache is not stored.
+func (a *assetCache) set(key string, entry *cachedAsset) {
+	if len(entry.data) > a.maxBytes {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if old, ok := a.entries[key]; ok {
+		a.size -= len(old.data)
+		delete(a.entries, key)
+		for i, k := range a.order {
+			if k == key {
+				a.order = append(a.order[:i], a.order[i+1:]...)
+				break
+			}
+		}
+	}
+	for len(a.order) > 0 && a.size+len(entry.data) > a.maxBytes {
+		oldest := a.order[0]
+		a.order = a.order[1:]
+		a.size -= len(a.entries[oldest].data)
+		delete(a.entries, oldest)
+	}
+	a.order = append(a.order, key)
+	a.entries[key] = entry
+	a.size += len(entry.data)
+}
@@ -1,45 +1,79 @@
 package client
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
 
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/metrics"
 	"github.com/conductorone/baton-sdk/pkg/ratelimit"
 	"github.com/conductorone/baton-sdk/pkg/uhttp"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
 	userEndpoint = "/api/user"
 	teamEndpoint = "/api/team"
 	teamsSummary = "/api/teams/summary"
+	apiKeys      = "/api/api_keys"
+	accessRoles  = "/api/access_roles"
+	customers    = "/api/customers"
+	portalUsers  = "portal_users"
+	territories  = "/api/territories"
+	skills       = "/api/skills"
 )
 
+// MaxAssetSize is the largest asset, in bytes, that the client will download.
+const MaxAssetSize = 5 << 20
+
+// zuperDomains are the domains Zuper serves profile pictures from.
+var zuperDomains = []string{"zuperpro.com", "zuper.co"}
+
 // Client is the Zuper API client for Baton.
 type Client struct {
-	apiUrl  string
-	apiKey  string
-	wrapper *uhttp.BaseHttpClient
+	apiUrl    string
+	keyMu     sync.RWMutex
+	apiKey    string
+	keySource APIKeySource
+	wrapper   *uhttp.BaseHttpClient
+	// uncached sends the reads that bypass the HTTP cache. uncachedErr is why it could not be built, if it is nil.
+	uncached    *uhttp.BaseHttpClient
+	uncachedErr error
+	assets      *assetCache
+	dryRun      bool
+	journal     *journal
+	limiter     *rateLimiter
+	breaker     *circuitBreaker
+	// assetHosts are the hosts besides Zuper's own that profile pictures may be downloaded from.
+	assetHosts []string
+	metrics    metrics.Handler
+	telemetry  *telemetry
 }
 
-func New(ctx context.Context, client *Client) (*Client, error) {
-	httpClient, err := uhttp.NewClient(ctx, uhttp.WithLogger(true, ctxzap.Extract(ctx)))
-	if err != nil {
-		return nil, err
-	}
-
-	cli, err := uhttp.NewBaseHttpClientWithContext(ctx, httpClient)
+// New returns a client for the Zuper API at apiUrl, sending requests through a single HTTP client configured
+// with transport.
+func New(ctx context.Context, apiUrl string, apiKey string, transport TransportOptions) (*Client, error) {
+	httpClient, err := NewHTTPClient(ctx, transport)
 	if err != nil {
 		return nil, err
 	}
-
-	return &Client{
-		wrapper: cli,
-		apiUrl:  client.apiUrl,
-		apiKey:  client.apiKey,
-	}, nil
+	return NewClient(ctx, apiUrl, apiKey, httpClient), nil
 }
 
 // NewClient creates a new Client instance with the provided HTTP client.
@@ -47,38 +81,57 @@ func NewClient(ctx context.Context, apiUrl string, apiKey string, httpClient *uh
 	if httpClient == nil {
 		httpClient = &uhttp.BaseHttpClient{}
 	}
+	handler := metrics.NewOtelHandler(ctx, otel.GetMeterProvider(), instrumentationName)
+	uncached, uncachedErr := newUncachedWrapper(ctx, httpClient.HttpClient)
 	return &Client{
-		wrapper: httpClient,
-		apiUrl:  apiUrl,
-		apiKey:  apiKey,
+		wrapper:     httpClient,
+		uncached:    uncached,
+		uncachedErr: uncachedErr,
+		apiUrl:      apiUrl,
+		apiKey:      apiKey,
+		assets:      newAssetCache(DefaultAssetCacheBytes),
+		metrics:     handler,
+		telemetry:   newTelemetry(handler),
 	}
 }
 
 // GetUsers fetches a paginated list of users from the Zuper API.
 func (c *Client) GetUsers(ctx context.Context, opts PageOptions) ([]*ZuperUser, string, annotations.Annotations, error) {
+	var users []*ZuperUser
+	nextToken, annos, err := c.EachUser(ctx, opts, func(user *ZuperUser) error {
+		users = append(users, user)
+		return nil
+	})
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return users, nextToken, annos, nil
+}
+
+// EachUser fetches a page of users from the Zuper API and decodes it one user at a time, handing each user to fn
+// instead of building a slice of the page. This does not bound memory by the user: the response body is read in
+// full before decoding starts. fn runs after the request is done, so it may call the client. It returns the token
+// of the next page, or the first error returned by fn.
+func (c *Client) EachUser(ctx context.Context, opts PageOptions, fn func(*ZuperUser) error) (string, annotations.Annotations, error) {
 	if opts.PageSize == 0 {
 		opts.PageSize = DefaultPageSize
 	}
 
 	usersURL, _, err := preparePagedRequest(c.apiUrl, userEndpoint, opts, "all")
 	if err != nil {
-		return nil, "", nil, err
+		return "", nil, err
 	}
 
-	var usersResponse UsersResponse
-	_, annos, err := c.doRequest(ctx, http.MethodGet, usersURL.String(), nil, &usersResponse)
-	if err != nil {
-		return nil, "", nil, err
+	decoder := newPageDecoder(fn, "data")
+	_, annos, err := c.doRequest(ctx, http.MethodGet, usersURL.String(), nil, decoder)
+	if decoder.err != nil {
+		return "", nil, decoder.err
 	}
-
-	nextToken := getNextToken(usersResponse.CurrentPage, usersResponse.TotalPages)
-
-	var users []*ZuperUser
-	for _, user := range usersResponse.Data {
-		users = append(users, &user)
+	if err != nil {
+		return "", nil, err
 	}
 
-	return users, nextToken, annos, nil
+	return getNextToken(decoder.CurrentPage, decoder.TotalPages), annos, nil
 }
 
 // GetUserByID fetches the details of a user by their user_uid from the Zuper API.
@@ -116,8 +169,16 @@ func (c *Client) CreateUser(ctx context.Context, user UserPayload) (*CreateUserR
 	if err != nil {
 		return nil, nil, err
 	}
+	entry := c.journalBefore(ctx, mutation{
+		operation: "create_user",
+		newValue:  user,
+	})
 	var result CreateUserResponse
 	_, annos, err := c.doRequest(ctx, http.MethodPost, userCreateURL, payload, &result)
+	if entry != nil {
+		entry.UserUID = result.Data.UserUID
+	}
+	c.journalAfter(ctx, entry, result.Message, err)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -127,48 +188,68 @@ func (c *Client) CreateUser(ctx context.Context, user UserPayload) (*CreateUserR
 
 // GetTeams fetches a paginated list of teams from the Zuper API.
 func (c *Client) GetTeams(ctx context.Context, opts PageOptions) ([]*Team, string, annotations.Annotations, error) {
+	var teams []*Team
+	nextToken, annos, err := c.EachTeam(ctx, opts, func(team *Team) error {
+		teams = append(teams, team)
+		return nil
+	})
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return teams, nextToken, annos, nil
+}
+
+// EachTeam fetches a page of teams from the Zuper API and decodes it one team at a time, handing each team to fn.
+// Like EachUser, the whole page is read before fn runs. It returns the token of the next page, or the first error
+// returned by fn.
+func (c *Client) EachTeam(ctx context.Context, opts PageOptions, fn func(*Team) error) (string, annotations.Annotations, error) {
 	if opts.PageSize == 0 {
 		opts.PageSize = DefaultPageSize
 	}
 
 	teamsURL, _, err := preparePagedRequest(c.apiUrl, teamsSummary, opts)
 	if err != nil {
-		return nil, "", nil, err
+		return "", nil, err
 	}
 
-	var teamsResponse TeamsResponse
-	_, annos, err := c.doRequest(ctx, http.MethodGet, teamsURL.String(), nil, &teamsResponse)
-	if err != nil {
-		return nil, "", nil, err
+	decoder := newPageDecoder(fn, "data")
+	_, annos, err := c.doRequest(ctx, http.MethodGet, teamsURL.String(), nil, decoder)
+	if decoder.err != nil {
+		return "", nil, decoder.err
 	}
-
-	nextToken := getNextToken(teamsResponse.CurrentPage, teamsResponse.TotalPages)
-
-	var teams []*Team
-	for _, team := range teamsResponse.Data {
-		teams = append(teams, &team)
+	if err != nil {
+		return "", nil, err
 	}
 
-	return teams, nextToken, annos, nil
+	return getNextToken(decoder.CurrentPage, decoder.TotalPages), annos, nil
 }
 
 // GetTeamUsers fetches the users of a team from the Zuper API.
 func (c *Client) GetTeamUsers(ctx context.Context, teamID string) ([]*ZuperUser, string, annotations.Annotations, error) {
-	teamDetailsURL, err := buildResourceURL(c.apiUrl, teamEndpoint, teamID)
+	var users []*ZuperUser
+	annos, err := c.EachTeamMember(ctx, teamID, func(user *ZuperUser) error {
+		users = append(users, user)
+		return nil
+	})
 	if err != nil {
-		return nil, "", nil, err
+		return nil, "", annos, err
 	}
-	var resp TeamDetailsWithUsersResponse
-	_, annos, err := c.doRequest(ctx, http.MethodGet, teamDetailsURL, nil, &resp)
+	return users, "", annos, nil
+}
+
+// EachTeamMember fetches the users of a team from the Zuper API and decodes them one at a time, handing each user
+// to fn. Like EachUser, the whole response is read before fn runs. It returns the first error returned by fn.
+func (c *Client) EachTeamMember(ctx context.Context, teamID string, fn func(*ZuperUser) error) (annotations.Annotations, error) {
+	teamDetailsURL, err := buildResourceURL(c.apiUrl, teamEndpoint, teamID)
 	if err != nil {
-		return nil, "", annos, err
+		return nil, err
 	}
-	var users []*ZuperUser
-	for _, user := range resp.Data.Users {
-		userCopy := user
-		users = append(users, &userCopy)
+	decoder := newPageDecoder(fn, "data", "users")
+	_, annos, err := c.doRequest(ctx, http.MethodGet, teamDetailsURL, nil, decoder)
+	if decoder.err != nil {
+		return annos, decoder.err
 	}
-	return users, "", annos, nil
+	return annos, err
 }
 
 // UpdateUserField updates a specific field of a user in Zuper.
@@ -182,8 +263,24 @@ func (c *Client) UpdateUserField(ctx context.Context, userUID string, field stri
 	if err != nil {
 		return nil, nil, err
 	}
+	entry := c.journalBefore(ctx, mutation{
+		operation:  "update_user_field",
+		userUID:    userUID,
+		targetType: "user",
+		targetUID:  userUID,
+		field:      field,
+		newValue:   value,
+		oldValue: func(ctx context.Context) (interface{}, error) {
+			user, _, err := c.GetUserByID(ctx, userUID)
+			if err != nil {
+				return nil, err
+			}
+			return userFieldValue(user, field), nil
+		},
+	})
 	var resp UpdateUserRoleResponse
 	_, annos, err := c.doRequest(ctx, http.MethodPut, url, payload, &resp)
+	c.journalAfter(ctx, entry, resp.Message, err)
 	if err != nil {
 		return nil, annos, err
 	}
@@ -200,6 +297,43 @@ func (c *Client) UpdateUserAccessRole(ctx context.Context, userUID string, acces
 	return c.UpdateUserField(ctx, userUID, "access_role", accessRoleUID)
 }
 
+// UpdateUserRoleIfUnchanged updates the role of a user only if neither its role nor its updated_at changed since
+// observed was read. Otherwise it returns a *ConflictError without writing.
+func (c *Client) UpdateUserRoleIfUnchanged(ctx context.Context, observed *ZuperUser, roleID int) (*UpdateUserRoleResponse, annotations.Annotations, error) {
+	return c.updateUserFieldIfUnchanged(ctx, observed, "role_id", roleID)
+}
+
+// UpdateUserAccessRoleIfUnchanged updates the access role of a user only if neither its access role nor its
+// updated_at changed since observed was read. Otherwise it returns a *ConflictError without writing.
+func (c *Client) UpdateUserAccessRoleIfUnchanged(ctx context.Context, observed *ZuperUser, accessRoleUID string) (*UpdateUserRoleResponse, annotations.Annotations, error) {
+	return c.updateUserFieldIfUnchanged(ctx, observed, "access_role", accessRoleUID)
+}
+
+// updateUserFieldIfUnchanged re-reads the user and compares it with observed before updating field.
+func (c *Client) updateUserFieldIfUnchanged(ctx context.Context, observed *ZuperUser, field string, value interface{}) (*UpdateUserRoleResponse, annotations.Annotations, error) {
+	current, _, err := c.GetUserByID(WithoutCache(ctx), observed.UserUID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to re-read user before update: %w", err)
+	}
+	expected, actual := userFieldValue(observed, field), userFieldValue(current, field)
+	if !reflect.DeepEqual(expected, actual) || observed.UpdatedAt != current.UpdatedAt {
+		return nil, nil, &ConflictError{
+			UserUID:           observed.UserUID,
+			Field:             field,
+			Expected:          expected,
+			Actual:            actual,
+			ExpectedUpdatedAt: observed.UpdatedAt,
+			ActualUpdatedAt:   current.UpdatedAt,
+		}
+	}
+	return c.UpdateUserField(ctx, observed.UserUID, field, value)
+}
+
+// DeactivateUser marks a user as inactive using UpdateUserField.
+func (c *Client) DeactivateUser(ctx context.Context, userUID string) (*UpdateUserRoleResponse, annotations.Annotations, error) {
+	return c.UpdateUserField(ctx, userUID, "is_active", false)
+}
+
 // AssignUserToTeam assigns a user to a team in Zuper using the teamUID and userUID.
 func (c *Client) AssignUserToTeam(ctx context.Context, teamUID string, userUID string) (*AssignUserToTeamResponse, annotations.Annotations, error) {
 	payload := AssignUserToTeamRequest{
@@ -210,8 +344,20 @@ func (c *Client) AssignUserToTeam(ctx context.Context, teamUID string, userUID s
 	if err != nil {
 		return nil, nil, err
 	}
+	entry := c.journalBefore(ctx, mutation{
+		operation:  "assign_user_to_team",
+		userUID:    userUID,
+		targetType: "team",
+		targetUID:  teamUID,
+		field:      "member",
+		newValue:   true,
+		oldValue: func(ctx context.Context) (interface{}, error) {
+			return c.IsUserInTeam(ctx, teamUID, userUID)
+		},
+	})
 	var resp AssignUserToTeamResponse
 	_, annos, err := c.doRequest(ctx, http.MethodPost, url, payload, &resp)
+	c.journalAfter(ctx, entry, resp.Message, err)
 	if err != nil {
 		return nil, annos, err
 	}
@@ -228,8 +374,20 @@ func (c *Client) UnassignUserFromTeam(ctx context.Context, teamUID string, userU
 	if err != nil {
 		return nil, nil, err
 	}
+	entry := c.journalBefore(ctx, mutation{
+		operation:  "unassign_user_from_team",
+		userUID:    userUID,
+		targetType: "team",
+		targetUID:  teamUID,
+		field:      "member",
+		newValue:   false,
+		oldValue: func(ctx context.Context) (interface{}, error) {
+			return c.IsUserInTeam(ctx, teamUID, userUID)
+		},
+	})
 	var resp AssignUserToTeamResponse
 	_, annos, err := c.doRequest(ctx, http.MethodPost, url, payload, &resp)
+	c.journalAfter(ctx, entry, resp.Message, err)
 	if err != nil {
 		return nil, annos, err
 	}
@@ -238,11 +396,10 @@ func (c *Client) UnassignUserFromTeam(ctx context.Context, teamUID string, userU
 
 // IsUserInTeam checks if a user is already a member of a team.
 func (c *Client) IsUserInTeam(ctx context.Context, teamUID string, userUID string) (bool, error) {
-	users, _, _, err := c.GetTeamUsers(ctx, teamUID)
-	if err != nil {
-		return false, err
-	}
-	for _, user := range users {
+	for user, err := range c.AllTeamMembers(ctx, teamUID, nil) {
+		if err != nil {
+			return false, err
+		}
 		if user.UserUID == userUID {
 			return true, nil
 		}
@@ -250,6 +407,493 @@ func (c *Client) IsUserInTeam(ctx context.Context, teamUID string, userUID strin
 	return false, nil
 }
 
+// GetAccessRolePermissions fetches the module permissions granted by an access role.
+func (c *Client) GetAccessRolePermissions(ctx context.Context, accessRoleUID string) ([]*ModulePermission, annotations.Annotations, error) {
+	roleURL, err := buildResourceURL(c.apiUrl, accessRoles, accessRoleUID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var resp AccessRoleDetailsResponse
+	_, annos, err := c.doRequest(ctx, http.MethodGet, roleURL, nil, &resp)
+	if err != nil {
+		return nil, annos, err
+	}
+	var permissions []*ModulePermission
+	for _, permission := range resp.Data.Permissions {
+		permissions = append(permissions, &permission)
+	}
+	return permissions, annos, nil
+}
+
+// GetTerritories fetches a paginated list of service territories from the Zuper API.
+func (c *Client) GetTerritories(ctx context.Context, opts PageOptions) ([]*Territory, string, annotations.Annotations, error) {
+	if opts.PageSize == 0 {
+		opts.PageSize = DefaultPageSize
+	}
+
+	territoriesURL, _, err := preparePagedRequest(c.apiUrl, territories, opts)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	var territoriesResponse TerritoriesResponse
+	_, annos, err := c.doRequest(ctx, http.MethodGet, territoriesURL.String(), nil, &territoriesResponse)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	nextToken := getNextToken(territoriesResponse.CurrentPage, territoriesResponse.TotalPages)
+
+	var result []*Territory
+	for _, territory := range territoriesResponse.Data {
+		result = append(result, &territory)
+	}
+
+	return result, nextToken, annos, nil
+}
+
+// GetTerritoryUsers fetches the users assigned to a service territory from the Zuper API.
+func (c *Client) GetTerritoryUsers(ctx context.Context, territoryUID string) ([]*ZuperUser, annotations.Annotations, error) {
+	territoryURL, err := buildResourceURL(c.apiUrl, territories, territoryUID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var resp TerritoryDetailsWithUsersResponse
+	_, annos, err := c.doRequest(ctx, http.MethodGet, territoryURL, nil, &resp)
+	if err != nil {
+		return nil, annos, err
+	}
+	var users []*ZuperUser
+	for _, user := range resp.Data.Users {
+		users = append(users, &user)
+	}
+	return users, annos, nil
+}
+
+// AssignUserToTerritory assigns a user to a service territory in Zuper.
+func (c *Client) AssignUserToTerritory(ctx context.Context, territoryUID string, userUID string) (*AssignUserToTerritoryResponse, annotations.Annotations, error) {
+	return c.updateTerritoryAssignment(ctx, "assign", territoryUID, userUID)
+}
+
+// UnassignUserFromTerritory removes a user from a service territory in Zuper.
+func (c *Client) UnassignUserFromTerritory(ctx context.Context, territoryUID string, userUID string) (*AssignUserToTerritoryResponse, annotations.Annotations, error) {
+	return c.updateTerritoryAssignment(ctx, "unassign", territoryUID, userUID)
+}
+
+// updateTerritoryAssignment posts a territory assignment change for a user.
+func (c *Client) updateTerritoryAssignment(ctx context.Context, action string, territoryUID string, userUID string) (*AssignUserToTerritoryResponse, annotations.Annotations, error) {
+	payload := AssignUserToTerritoryRequest{
+		TerritoryUID: territoryUID,
+		UserUID:      userUID,
+	}
+	url, err := buildResourceURL(c.apiUrl, territories, action)
+	if err != nil {
+		return nil, nil, err
+	}
+	entry := c.journalBefore(ctx, mutation{
+		operation:  action + "_user_territory",
+		userUID:    userUID,
+		targetType: "territory",
+		targetUID:  territoryUID,
+		field:      "member",
+		newValue:   action == "assign",
+		oldValue: func(ctx context.Context) (interface{}, error) {
+			users, _, err := c.GetTerritoryUsers(ctx, territoryUID)
+			if err != nil {
+				return nil, err
+			}
+			for _, user := range users {
+				if user.UserUID == userUID {
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+	})
+	var resp AssignUserToTerritoryResponse
+	_, annos, err := c.doRequest(ctx, http.MethodPost, url, payload, &resp)
+	c.journalAfter(ctx, entry, resp.Message, err)
+	if err != nil {
+		return nil, annos, err
+	}
+	return &resp, annos, nil
+}
+
+// GetSkills fetches a paginated list of skills from the Zuper API.
+func (c *Client) GetSkills(ctx context.Context, opts PageOptions) ([]*Skill, string, annotations.Annotations, error) {
+	if opts.PageSize == 0 {
+		opts.PageSize = DefaultPageSize
+	}
+
+	skillsURL, _, err := preparePagedRequest(c.apiUrl, skills, opts)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	var skillsResponse SkillsResponse
+	_, annos, err := c.doRequest(ctx, http.MethodGet, skillsURL.String(), nil, &skillsResponse)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	nextToken := getNextToken(skillsResponse.CurrentPage, skillsResponse.TotalPages)
+
+	var result []*Skill
+	for _, skill := range skillsResponse.Data {
+		result = append(result, &skill)
+	}
+
+	return result, nextToken, annos, nil
+}
+
+// GetSkillUsers fetches the users holding a skill from the Zuper API.
+func (c *Client) GetSkillUsers(ctx context.Context, skillUID string) ([]*UserSkill, annotations.Annotations, error) {
+	skillUsersURL, err := buildResourceURL(c.apiUrl, skills, skillUID, "users")
+	if err != nil {
+		return nil, nil, err
+	}
+	var resp SkillUsersResponse
+	_, annos, err := c.doRequest(ctx, http.MethodGet, skillUsersURL, nil, &resp)
+	if err != nil {
+		return nil, annos, err
+	}
+	var users []*UserSkill
+	for _, user := range resp.Data {
+		users = append(users, &user)
+	}
+	return users, annos, nil
+}
+
+// AssignSkillToUser assigns a skill to a user in Zuper. An empty expiresAt assigns the skill without expiry.
+func (c *Client) AssignSkillToUser(ctx context.Context, skillUID string, userUID string, expiresAt string) (*AssignSkillToUserResponse, annotations.Annotations, error) {
+	payload := AssignSkillToUserRequest{
+		SkillUID:  skillUID,
+		UserUID:   userUID,
+		ExpiresAt: expiresAt,
+	}
+	url, err := buildResourceURL(c.apiUrl, skills, "assign")
+	if err != nil {
+		return nil, nil, err
+	}
+	entry := c.journalBefore(ctx, mutation{
+		operation:  "assign_skill_to_user",
+		userUID:    userUID,
+		targetType: "skill",
+		targetUID:  skillUID,
+		field:      "has_skill",
+		newValue:   true,
+		oldValue: func(ctx context.Context) (interface{}, error) {
+			return c.userHasSkill(ctx, skillUID, userUID)
+		},
+	})
+	var resp AssignSkillToUserResponse
+	_, annos, err := c.doRequest(ctx, http.MethodPost, url, payload, &resp)
+	c.journalAfter(ctx, entry, resp.Message, err)
+	if err != nil {
+		return nil, annos, err
+	}
+	return &resp, annos, nil
+}
+
+// UnassignSkillFromUser removes a skill from a user in Zuper.
+func (c *Client) UnassignSkillFromUser(ctx context.Context, skillUID string, userUID string) (*AssignSkillToUserResponse, annotations.Annotations, error) {
+	payload := AssignSkillToUserRequest{
+		SkillUID: skillUID,
+		UserUID:  userUID,
+	}
+	url, err := buildResourceURL(c.apiUrl, skills, "unassign")
+	if err != nil {
+		return nil, nil, err
+	}
+	entry := c.journalBefore(ctx, mutation{
+		operation:  "unassign_skill_from_user",
+		userUID:    userUID,
+		targetType: "skill",
+		targetUID:  skillUID,
+		field:      "has_skill",
+		newValue:   false,
+		oldValue: func(ctx context.Context) (interface{}, error) {
+			return c.userHasSkill(ctx, skillUID, userUID)
+		},
+	})
+	var resp AssignSkillToUserResponse
+	_, annos, err := c.doRequest(ctx, http.MethodPost, url, payload, &resp)
+	c.journalAfter(ctx, entry, resp.Message, err)
+	if err != nil {
+		return nil, annos, err
+	}
+	return &resp, annos, nil
+}
+
+// GetCustomers fetches a paginated list of customers from the Zuper API.
+func (c *Client) GetCustomers(ctx context.Context, opts PageOptions) ([]*Customer, string, annotations.Annotations, error) {
+	if opts.PageSize == 0 {
+		opts.PageSize = DefaultPageSize
+	}
+
+	customersURL, _, err := preparePagedRequest(c.apiUrl, customers, opts)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	var customersResponse CustomersResponse
+	_, annos, err := c.doRequest(ctx, http.MethodGet, customersURL.String(), nil, &customersResponse)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	nextToken := getNextToken(customersResponse.CurrentPage, customersResponse.TotalPages)
+
+	var result []*Customer
+	for _, customer := range customersResponse.Data {
+		result = append(result, &customer)
+	}
+
+	return result, nextToken, annos, nil
+}
+
+// GetCustomerPortalUsers fetches a paginated list of portal users belonging to a customer.
+func (c *Client) GetCustomerPortalUsers(ctx context.Context, customerUID string, opts PageOptions) ([]*CustomerPortalUser, string, annotations.Annotations, error) {
+	if opts.PageSize == 0 {
+		opts.PageSize = DefaultPageSize
+	}
+
+	portalUsersURL, _, err := preparePagedRequest(c.apiUrl, customers, opts, customerUID, portalUsers)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	var portalUsersResponse CustomerPortalUsersResponse
+	_, annos, err := c.doRequest(ctx, http.MethodGet, portalUsersURL.String(), nil, &portalUsersResponse)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	nextToken := getNextToken(portalUsersResponse.CurrentPage, portalUsersResponse.TotalPages)
+
+	var users []*CustomerPortalUser
+	for _, user := range portalUsersResponse.Data {
+		users = append(users, &user)
+	}
+
+	return users, nextToken, annos, nil
+}
+
+// HasPortalAccess checks if a customer portal user has access to the customer's portal.
+func (c *Client) HasPortalAccess(ctx context.Context, customerUID string, portalUserUID string) (bool, error) {
+	access, err := c.portalAccess(ctx, customerUID, portalUserUID)
+	if err != nil {
+		return false, err
+	}
+	hasAccess, _ := access.(bool)
+	return hasAccess, nil
+}
+
+// UpdateCustomerPortalAccess enables or disables portal access for a customer portal user.
+func (c *Client) UpdateCustomerPortalAccess(ctx context.Context, customerUID string, portalUserUID string, enabled bool) (*UpdatePortalAccessResponse, annotations.Annotations, error) {
+	payload := UpdatePortalAccessRequest{
+		PortalAccess: enabled,
+	}
+	url, err := buildResourceURL(c.apiUrl, customers, customerUID, portalUsers, portalUserUID)
+	if err != nil {
+		return nil, nil, err
+	}
+	entry := c.journalBefore(ctx, mutation{
+		operation:  "update_customer_portal_access",
+		userUID:    portalUserUID,
+		targetType: "customer",
+		targetUID:  customerUID,
+		field:      "portal_access",
+		newValue:   enabled,
+		oldValue: func(ctx context.Context) (interface{}, error) {
+			return c.portalAccess(ctx, customerUID, portalUserUID)
+		},
+	})
+	var resp UpdatePortalAccessResponse
+	_, annos, err := c.doRequest(ctx, http.MethodPut, url, payload, &resp)
+	c.journalAfter(ctx, entry, resp.Message, err)
+	if err != nil {
+		return nil, annos, err
+	}
+	return &resp, annos, nil
+}
+
+// GetAPIKeys fetches a paginated list of API keys from the Zuper API.
+func (c *Client) GetAPIKeys(ctx context.Context, opts PageOptions) ([]*APIKey, string, annotations.Annotations, error) {
+	if opts.PageSize == 0 {
+		opts.PageSize = DefaultPageSize
+	}
+
+	keysURL, _, err := preparePagedRequest(c.apiUrl, apiKeys, opts)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	var keysResponse APIKeysResponse
+	_, annos, err := c.doRequest(ctx, http.MethodGet, keysURL.String(), nil, &keysResponse)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	nextToken := getNextToken(keysResponse.CurrentPage, keysResponse.TotalPages)
+
+	var keys []*APIKey
+	for _, key := range keysResponse.Data {
+		keys = append(keys, &key)
+	}
+
+	return keys, nextToken, annos, nil
+}
+
+// DeleteAPIKey revokes an API key in Zuper using its apiKeyUID.
+func (c *Client) DeleteAPIKey(ctx context.Context, apiKeyUID string) (*DeleteAPIKeyResponse, annotations.Annotations, error) {
+	url, err := buildResourceURL(c.apiUrl, apiKeys, apiKeyUID)
+	if err != nil {
+		return nil, nil, err
+	}
+	entry := c.journalBefore(ctx, mutation{
+		operation:  "delete_api_key",
+		targetType: "api_key",
+		targetUID:  apiKeyUID,
+		field:      "deleted",
+		newValue:   true,
+	})
+	var resp DeleteAPIKeyResponse
+	_, annos, err := c.doRequest(ctx, http.MethodDelete, url, nil, &resp)
+	c.journalAfter(ctx, entry, resp.Message, err)
+	if err != nil {
+		return nil, annos, err
+	}
+	return &resp, annos, nil
+}
+
+// IsAPIHost reports whether u points at the Zuper API host of the client.
+func (c *Client) IsAPIHost(u *url.URL) bool {
+	apiURL, err := url.Parse(c.apiUrl)
+	return err == nil && strings.EqualFold(apiURL.Host, u.Host)
+}
+
+// SetAssetHosts adds hosts profile pictures may be downloaded from, besides the Zuper API host and Zuper's own
+// domains. A host also allows its subdomains.
+func (c *Client) SetAssetHosts(hosts []string) {
+	c.assetHosts = hosts
+}
+
+// isAssetHost reports whether a profile picture at u may be downloaded: it must be on the Zuper API host, on one of
+// Zuper's domains or on a host added with SetAssetHosts.
+func (c *Client) isAssetHost(u *url.URL) bool {
+	if c.IsAPIHost(u) {
+		return true
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range append(zuperDomains, c.assetHosts...) {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetProfilePicture downloads a user profile picture, returning its content type and contents. Pictures are only
+// downloaded from the hosts isAssetHost allows, and the API key is only sent to the Zuper API host. The download
+// goes through the circuit breaker, the rate limiter and telemetry like any other request, bypassing the HTTP
+// response cache since pictures are kept in their own cache, which is bounded by DefaultAssetCacheBytes.
+func (c *Client) GetProfilePicture(ctx context.Context, pictureURL string) (string, io.ReadCloser, error) {
+	if cached, ok := c.assets.get(pictureURL); ok {
+		return cached.contentType, io.NopCloser(bytes.NewReader(cached.data)), nil
+	}
+
+	parsedURL, err := url.Parse(pictureURL)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid profile picture URL: %w", err)
+	}
+	if parsedURL.Scheme != "https" && parsedURL.Scheme != "http" {
+		return "", nil, fmt.Errorf("unsupported profile picture URL scheme: %s", parsedURL.Scheme)
+	}
+	if !c.isAssetHost(parsedURL) {
+		return "", nil, fmt.Errorf("profile picture host %s is not a Zuper or asset host", parsedURL.Host)
+	}
+
+	requestOptions := []uhttp.RequestOption{uhttp.WithAccept("image/*")}
+	if c.IsAPIHost(parsedURL) {
+		requestOptions = append(requestOptions, uhttp.WithHeader("x-api-key", c.currentAPIKey()))
+	}
+
+	ctx, span := startSpan(ctx, http.MethodGet, assetEndpoint, nil)
+	req, err := c.wrapper.NewRequest(ctx, http.MethodGet, parsedURL, requestOptions...)
+	if err != nil {
+		endSpan(span, 0, err)
+		return "", nil, err
+	}
+	var asset cachedAsset
+	_, _, err = c.roundTrip(ctx, req, assetEndpoint, c.downloadAsset(&asset))
+	if err == nil && asset.data == nil {
+		err = errors.New("empty profile picture response")
+	}
+	endSpan(span, 0, err)
+	if err != nil {
+		return "", nil, err
+	}
+
+	c.assets.set(pictureURL, &asset)
+	return asset.contentType, io.NopCloser(bytes.NewReader(asset.data)), nil
+}
+
+// downloadAsset returns a sender that reads a successful image response into asset. It sends the request with the
+// underlying HTTP client rather than the wrapper, which buffers whole bodies, so a picture larger than MaxAssetSize
+// is rejected by its Content-Length or after reading one byte past the limit, never loaded in full.
+func (c *Client) downloadAsset(asset *cachedAsset) func(*http.Request, ...uhttp.DoOption) (*http.Response, error) {
+	return func(req *http.Request, _ ...uhttp.DoOption) (*http.Response, error) {
+		resp, err := c.wrapper.HttpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if err := assetStatusError(resp); err != nil {
+			return resp, err
+		}
+		if resp.ContentLength > MaxAssetSize {
+			return resp, fmt.Errorf("profile picture exceeds maximum size of %d bytes", MaxAssetSize)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, MaxAssetSize+1))
+		if err != nil {
+			return resp, uhttp.WrapErrors(codes.Unavailable, "reading profile picture", err)
+		}
+		if len(data) > MaxAssetSize {
+			return resp, fmt.Errorf("profile picture exceeds maximum size of %d bytes", MaxAssetSize)
+		}
+		contentType := resp.Header.Get(uhttp.ContentType)
+		if contentType == "" {
+			contentType = http.DetectContentType(data)
+		}
+		if !strings.HasPrefix(contentType, "image/") {
+			return resp, fmt.Errorf("unexpected profile picture content type: %s", contentType)
+		}
+		asset.contentType = contentType
+		asset.data = data
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+		return resp, nil
+	}
+}
+
+// assetStatusError maps an unsuccessful asset response to the gRPC error the HTTP wrapper would return for it.
+func assetStatusError(resp *http.Response) error {
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return uhttp.WrapErrorsWithRateLimitInfo(codes.Unavailable, resp)
+	case resp.StatusCode == http.StatusNotFound:
+		return uhttp.WrapErrorsWithRateLimitInfo(codes.NotFound, resp)
+	case resp.StatusCode == http.StatusUnauthorized:
+		return uhttp.WrapErrorsWithRateLimitInfo(codes.Unauthenticated, resp)
+	case resp.StatusCode == http.StatusForbidden:
+		return uhttp.WrapErrorsWithRateLimitInfo(codes.PermissionDenied, resp)
+	}
+	return uhttp.WrapErrorsWithRateLimitInfo(codes.Unknown, resp, fmt.Errorf("unexpected status code: %d", resp.StatusCode))
+}
+
 // doRequest executes an HTTP request and decodes the response into the provided result.
 func (c *Client) doRequest(
 	ctx context.Context,
@@ -262,12 +906,60 @@ func (c *Client) doRequest(
 	if err != nil {
 		return nil, nil, err
 	}
+	if c.dryRun && isMutation(method) {
+		return c.dryRunRequest(ctx, method, parsedURL.String(), body)
+	}
 
+	var raw *[]byte
+	if res != nil {
+		raw = new([]byte)
+	}
+	ctx, span := startSpan(ctx, method, endpointTemplate(parsedURL.Path), parsedURL.Query())
+	retries := 0
+	apiKey := c.currentAPIKey()
+	header, annos, err := c.send(ctx, method, parsedURL, body, raw, apiKey)
+	if status.Code(err) == codes.Unauthenticated {
+		// The key may have been rotated: reload it and retry once with the new key.
+		refreshed, refreshErr := c.refreshAPIKey(ctx, apiKey)
+		if refreshErr != nil {
+			ctxzap.Extract(ctx).Warn("failed to reload API key", zap.Error(refreshErr))
+		}
+		if refreshed {
+			retries++
+			header, annos, err = c.send(ctx, method, parsedURL, body, raw, c.currentAPIKey())
+		}
+	}
+	if err == nil && res != nil {
+		// The response is decoded once the request has given back its in-flight slot, so that the callbacks of a
+		// pageDecoder may send requests of their own.
+		if err = json.Unmarshal(*raw, res); err != nil {
+			err = fmt.Errorf("failed to unmarshal json response: %w", err)
+		}
+	}
+	if errors.Is(err, errDecodeStopped) {
+		// The caller stopped the decoding, for instance by breaking out of an iterator: the request itself succeeded.
+		endSpan(span, retries, nil)
+	} else {
+		endSpan(span, retries, err)
+	}
+	return header, annos, err
+}
+
+// send sends a single request to Zuper authenticated with apiKey. If raw is not nil, it is set to the JSON body
+// of a successful response.
+func (c *Client) send(
+	ctx context.Context,
+	method string,
+	parsedURL *url.URL,
+	body interface{},
+	raw *[]byte,
+	apiKey string,
+) (http.Header, annotations.Annotations, error) {
 	var zuperErr ZuperError
 	requestOptions := []uhttp.RequestOption{
 		uhttp.WithContentTypeJSONHeader(),
 		uhttp.WithAcceptJSONHeader(),
-		uhttp.WithHeader("x-api-key", c.apiKey),
+		uhttp.WithHeader("x-api-key", apiKey),
 	}
 	if body != nil {
 		requestOptions = append(requestOptions, uhttp.WithJSONBody(body))
@@ -284,21 +976,87 @@ func (c *Client) doRequest(
 	}
 
 	var doOptions []uhttp.DoOption
-	if res != nil {
-		doOptions = append(doOptions, uhttp.WithJSONResponse(res))
+	if raw != nil {
+		doOptions = append(doOptions, withRawJSONResponse(raw))
 	}
 	doOptions = append(doOptions, uhttp.WithErrorResponse(&zuperErr))
 
-	resp, err := c.wrapper.Do(req, doOptions...)
+	do := c.wrapper.Do
+	if method == http.MethodGet && skipCache(ctx) {
+		if c.uncached == nil {
+			return nil, nil, fmt.Errorf("failed to build the uncached HTTP client: %w", c.uncachedErr)
+		}
+		do = c.uncached.Do
+	}
+	resp, rateLimit, err := c.roundTrip(ctx, req, endpointTemplate(parsedURL.Path), do, doOptions...)
 	if err != nil {
 		return nil, nil, err
 	}
-	defer resp.Body.Close()
 
 	annos := annotations.Annotations{}
-	if desc, err := ratelimit.ExtractRateLimitData(resp.StatusCode, &resp.Header); err == nil {
-		annos.WithRateLimiting(desc)
+	if rateLimit != nil {
+		annos.WithRateLimiting(rateLimit)
 	}
 
 	return resp.Header, annos, nil
 }
+
+// roundTrip sends req through the circuit breaker and the rate limiter, recording its telemetry under endpoint, and
+// returns the response along with the rate limit Zuper reported. The request is sent with do, which is the HTTP
+// wrapper's Do for most requests.
+// The response body is read in full, and the in-flight slot given back, before roundTrip returns.
+func (c *Client) roundTrip(
+	ctx context.Context,
+	req *http.Request,
+	endpoint string,
+	do func(*http.Request, ...uhttp.DoOption) (*http.Response, error),
+	doOptions ...uhttp.DoOption,
+) (*http.Response, *v2.RateLimitDescription, error) {
+	call, err := c.breaker.allow(ctx, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	waitStart := time.Now()
+	release, err := c.limiter.wait(ctx)
+	if err != nil {
+		call.cancel()
+		return nil, nil, err
+	}
+	start := time.Now()
+	c.telemetry.recordRateLimitWait(ctx, endpoint, start.Sub(waitStart))
+	resp, err := do(req, doOptions...)
+	release()
+	call.done(resp, err)
+
+	statusCode := 0
+	if resp != nil {
+		statusCode = resp.StatusCode
+		trace.SpanFromContext(ctx).SetAttributes(attrStatusCode.Int(statusCode))
+	}
+	c.telemetry.recordAttempt(ctx, req.Method, endpoint, statusCode, time.Since(start), err)
+
+	var rateLimit *v2.RateLimitDescription
+	if resp != nil {
+		if desc, descErr := ratelimit.ExtractRateLimitData(resp.StatusCode, &resp.Header); descErr == nil {
+			rateLimit = desc
+			c.limiter.observe(ctx, desc, time.Now())
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	resp.Body.Close()
+	return resp, rateLimit, nil
+}
+
+// withRawJSONResponse keeps the body of a JSON response in raw without decoding it. The body was already read in
+// full by the HTTP wrapper, so this does not copy it.
+func withRawJSONResponse(raw *[]byte) uhttp.DoOption {
+	return func(resp *uhttp.WrapperResponse) error {
+		if contentType := resp.Header.Get(uhttp.ContentType); !uhttp.IsJSONContentType(contentType) {
+			return fmt.Errorf("unexpected content type for JSON response: %s. status code: %d", contentType, resp.StatusCode)
+		}
+		*raw = resp.Body
+		return nil
+	}
+}
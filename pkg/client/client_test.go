@@ -3,14 +3,38 @@ package client
 import (
 	"context"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/metrics"
 	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // loadUsersResponseFromMock loads a UsersResponse from a mock JSON file for testing.
@@ -231,6 +255,36 @@ func TestGetUserByID(t *testing.T) {
 	})
 }
 
+// TestRoleFieldNormalization tests that roles and access roles decode from the alternative field names Zuper uses.
+func TestRoleFieldNormalization(t *testing.T) {
+	t.Run("users fixture", func(t *testing.T) {
+		users := loadUsersResponseFromMock("users_success.json").Data
+		assert.Len(t, users, 1)
+		assert.Equal(t, "Field Executive", users[0].Role.RoleName)
+		assert.Equal(t, "Zuper Manager", users[0].AccessRole.AccessRoleName)
+		assert.Equal(t, "Supervisor or Managerial functions; All Zuper Permissions", users[0].AccessRole.RoleDescription)
+		assert.Empty(t, users[0].AccessRole.AccessRoleUID)
+	})
+
+	t.Run("alternative names and numeric IDs", func(t *testing.T) {
+		var user ZuperUser
+		err := json.Unmarshal([]byte(`{
+			"user_uid": "u1",
+			"role": {"role_id": 3, "role_name": " Field Executive ", "role_key": "FIELD_EXECUTIVE"},
+			"access_role": {"access_role_uid": "ar-1", "role_name": "", "access_role_name": "Technician"}
+		}`), &user)
+		assert.NoError(t, err)
+		assert.Equal(t, &Role{RoleUID: "3", RoleName: "Field Executive", RoleKey: "FIELD_EXECUTIVE"}, user.Role)
+		assert.Equal(t, &AccessRole{AccessRoleUID: "ar-1", AccessRoleName: "Technician"}, user.AccessRole)
+	})
+
+	t.Run("null role", func(t *testing.T) {
+		var user ZuperUser
+		assert.NoError(t, json.Unmarshal([]byte(`{"user_uid": "u1", "role": null}`), &user))
+		assert.Nil(t, user.Role)
+	})
+}
+
 // TestDoRequestInvalidURL tests the behavior of the doRequest method
 // when provided with an invalid URL, expecting it to return an error.
 func TestDoRequestInvalidURL(t *testing.T) {
@@ -331,3 +385,966 @@ func TestGetTeamUsers(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+// TestGetProfilePicture tests downloading, caching and size limits for profile pictures.
+func TestGetProfilePicture(t *testing.T) {
+	t.Run("success, cached after first download", func(t *testing.T) {
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			assert.Equal(t, "dummy-token", r.Header.Get("x-api-key"))
+			w.Header().Set("Content-Type", "image/png")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("png-bytes"))
+		}))
+		defer server.Close()
+
+		ctx := context.Background()
+		httpClient, _ := uhttp.NewBaseHttpClientWithContext(ctx, &http.Client{})
+		client := NewClient(ctx, server.URL, "dummy-token", httpClient)
+
+		for i := 0; i < 2; i++ {
+			contentType, body, err := client.GetProfilePicture(ctx, server.URL+"/avatars/user-1.png")
+			assert.NoError(t, err)
+			assert.Equal(t, "image/png", contentType)
+			data, err := io.ReadAll(body)
+			assert.NoError(t, err)
+			assert.Equal(t, "png-bytes", string(data))
+		}
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("api key not sent to other hosts", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Empty(t, r.Header.Get("x-api-key"))
+			w.Header().Set("Content-Type", "image/jpeg")
+			_, _ = w.Write([]byte("jpeg-bytes"))
+		}))
+		defer server.Close()
+
+		ctx := context.Background()
+		httpClient, _ := uhttp.NewBaseHttpClientWithContext(ctx, &http.Client{})
+		client := NewClient(ctx, "https://mock.api.zuper.co", "dummy-token", httpClient)
+		client.SetAssetHosts([]string{"127.0.0.1"})
+
+		_, _, err := client.GetProfilePicture(ctx, server.URL+"/avatar.jpg")
+		assert.NoError(t, err)
+	})
+
+	t.Run("error, host not allowed", func(t *testing.T) {
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.Header().Set("Content-Type", "image/jpeg")
+			_, _ = w.Write([]byte("jpeg-bytes"))
+		}))
+		defer server.Close()
+
+		client := mustNew(t, "https://mock.api.zuper.co", TransportOptions{})
+		_, _, err := client.GetProfilePicture(context.Background(), server.URL+"/avatar.jpg")
+		assert.ErrorContains(t, err, "is not a Zuper or asset host")
+		assert.Zero(t, calls)
+
+		assert.True(t, client.isAssetHost(&url.URL{Host: "assets.zuperpro.com"}))
+		assert.True(t, client.isAssetHost(&url.URL{Host: "MOCK.api.zuper.co"}))
+		assert.False(t, client.isAssetHost(&url.URL{Host: "zuperpro.com.example.org"}))
+	})
+
+	t.Run("error, redirect to another host", func(t *testing.T) {
+		other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write([]byte("png-bytes"))
+		}))
+		defer other.Close()
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, other.URL+"/avatar.png", http.StatusFound)
+		}))
+		defer server.Close()
+
+		client := mustNew(t, server.URL, TransportOptions{})
+		_, _, err := client.GetProfilePicture(context.Background(), server.URL+"/avatar.png")
+		assert.ErrorContains(t, err, "refusing redirect")
+	})
+
+	t.Run("error status goes through the breaker", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		client := mustNew(t, server.URL, TransportOptions{})
+		client.SetCircuitBreaker(CircuitBreakerOptions{Threshold: 1, CoolDown: time.Minute})
+		_, _, err := client.GetProfilePicture(context.Background(), server.URL+"/avatar.png")
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		assert.Equal(t, breakerOpen, client.breaker.state)
+		assert.Equal(t, map[string]int64{"GET {asset}": 1}, client.TakeAPICalls())
+	})
+
+	t.Run("error, asset too large", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write(make([]byte, MaxAssetSize+1))
+		}))
+		defer server.Close()
+
+		ctx := context.Background()
+		httpClient, _ := uhttp.NewBaseHttpClientWithContext(ctx, &http.Client{})
+		client := NewClient(ctx, server.URL, "dummy-token", httpClient)
+
+		_, _, err := client.GetProfilePicture(ctx, server.URL+"/huge.png")
+		assert.Error(t, err)
+	})
+
+	t.Run("error, declared size too large", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			w.Header().Set("Content-Length", strconv.Itoa(MaxAssetSize+1))
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("png-bytes"))
+		}))
+		defer server.Close()
+
+		client := mustNew(t, server.URL, TransportOptions{})
+		_, _, err := client.GetProfilePicture(context.Background(), server.URL+"/huge.png")
+		assert.ErrorContains(t, err, "exceeds maximum size")
+	})
+
+	t.Run("error, not an image", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte("<html></html>"))
+		}))
+		defer server.Close()
+
+		ctx := context.Background()
+		httpClient, _ := uhttp.NewBaseHttpClientWithContext(ctx, &http.Client{})
+		client := NewClient(ctx, server.URL, "dummy-token", httpClient)
+
+		_, _, err := client.GetProfilePicture(ctx, server.URL+"/page")
+		assert.Error(t, err)
+	})
+}
+
+func TestAssetCache_BoundedByBytes(t *testing.T) {
+	cache := newAssetCache(10)
+	cache.set("a", &cachedAsset{data: []byte("aaaa")})
+	cache.set("b", &cachedAsset{data: []byte("bbbb")})
+	cache.set("c", &cachedAsset{data: []byte("cccc")})
+
+	_, ok := cache.get("a")
+	assert.False(t, ok, "oldest asset evicted to fit the new one")
+	_, ok = cache.get("b")
+	assert.True(t, ok)
+	_, ok = cache.get("c")
+	assert.True(t, ok)
+	assert.Equal(t, 8, cache.size)
+
+	cache.set("huge", &cachedAsset{data: make([]byte, 11)})
+	_, ok = cache.get("huge")
+	assert.False(t, ok, "asset larger than the cache is not stored")
+	assert.Equal(t, 8, cache.size)
+
+	cache.set("b", &cachedAsset{data: []byte("bbbbbbbb")})
+	_, ok = cache.get("c")
+	assert.False(t, ok)
+	assert.Equal(t, 8, cache.size)
+}
+
+func TestDryRun(t *testing.T) {
+	var mutations int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			mutations++
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(loadUsersResponseFromMock("users_success.json"))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	httpClient, _ := uhttp.NewBaseHttpClientWithContext(ctx, &http.Client{})
+	client := NewClient(ctx, server.URL, "dummy-token", httpClient)
+	client.SetDryRun(true)
+
+	t.Run("reads still execute", func(t *testing.T) {
+		users, _, _, err := client.GetUsers(ctx, PageOptions{PageSize: 10})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, users)
+	})
+
+	t.Run("mutations are annotated, not sent", func(t *testing.T) {
+		_, annos, err := client.AssignUserToTeam(ctx, "team-1", "user-1")
+		assert.NoError(t, err)
+
+		var request structpb.Struct
+		ok, err := annos.Pick(&request)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		fields := request.AsMap()
+		assert.Equal(t, true, fields["dry_run"])
+		assert.Equal(t, http.MethodPost, fields["method"])
+		assert.Equal(t, server.URL+"/api/team/assign", fields["url"])
+		assert.JSONEq(t, `{"team_uid":"team-1","user_uid":"user-1"}`, fields["body"].(string))
+
+		_, _, err = client.UpdateUserField(ctx, "user-1", "is_active", false)
+		assert.NoError(t, err)
+		_, _, err = client.UnassignUserFromTeam(ctx, "team-1", "user-1")
+		assert.NoError(t, err)
+		_, _, err = client.CreateUser(ctx, UserPayload{Email: "new@example.com"})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, mutations)
+	})
+}
+
+func TestJournal(t *testing.T) {
+	mockData, err := os.ReadFile("../../test/mock/user_details_success.json")
+	assert.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		switch {
+		case r.Method == http.MethodGet:
+			_, _ = w.Write(mockData)
+		case r.URL.Path == "/api/user":
+			_ = json.NewEncoder(w).Encode(CreateUserResponse{Message: "User created successfully"})
+		default:
+			_ = json.NewEncoder(w).Encode(UpdateUserRoleResponse{Message: "User updated"})
+		}
+	}))
+	defer server.Close()
+
+	ctx := WithCorrelationID(context.Background(), "req-123")
+	httpClient, _ := uhttp.NewBaseHttpClientWithContext(ctx, &http.Client{})
+	client := NewClient(ctx, server.URL, "dummy-token", httpClient)
+	path := t.TempDir() + "/journal.jsonl"
+	assert.NoError(t, client.SetJournal(path))
+
+	_, _, err = client.DeactivateUser(ctx, "user-1")
+	assert.NoError(t, err)
+	_, _, err = client.CreateUser(ctx, UserPayload{Email: "new@example.com", Password: "s3cret!"})
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "s3cret!")
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, lines, 2)
+
+	var update JournalEntry
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &update))
+	assert.Equal(t, "update_user_field", update.Operation)
+	assert.Equal(t, "req-123", update.CorrelationID)
+	assert.Equal(t, "user-1", update.UserUID)
+	assert.Equal(t, "is_active", update.Field)
+	assert.Equal(t, false, update.OldValue)
+	assert.Equal(t, false, update.NewValue)
+	assert.Equal(t, "User updated", update.Message)
+	assert.False(t, update.Timestamp.IsZero())
+
+	var create JournalEntry
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &create))
+	assert.Equal(t, "create_user", create.Operation)
+	assert.Equal(t, redacted, create.NewValue.(map[string]interface{})["password"])
+}
+
+func TestUpdateUserAccessRoleIfUnchanged(t *testing.T) {
+	current := ZuperUser{
+		UserUID:    "user-1",
+		UpdatedAt:  "2025-01-02 10:00:00",
+		AccessRole: &AccessRole{AccessRoleUID: "manager"},
+	}
+	var writes int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_ = json.NewEncoder(w).Encode(UserDetailsResponse{Type: "success", Data: current})
+			return
+		}
+		writes++
+		_ = json.NewEncoder(w).Encode(UpdateUserRoleResponse{Message: "User updated"})
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	httpClient, _ := uhttp.NewBaseHttpClientWithContext(ctx, &http.Client{})
+	client := NewClient(ctx, server.URL, "dummy-token", httpClient)
+
+	t.Run("writes when unchanged", func(t *testing.T) {
+		observed := current
+		resp, _, err := client.UpdateUserAccessRoleIfUnchanged(ctx, &observed, "technician")
+		assert.NoError(t, err)
+		assert.Equal(t, "User updated", resp.Message)
+		assert.Equal(t, 1, writes)
+	})
+
+	t.Run("refuses when changed in between", func(t *testing.T) {
+		observed := current
+		current.AccessRole = &AccessRole{AccessRoleUID: "admin"}
+		current.UpdatedAt = "2025-01-02 10:05:00"
+
+		_, _, err := client.UpdateUserAccessRoleIfUnchanged(ctx, &observed, "technician")
+		var conflict *ConflictError
+		assert.ErrorAs(t, err, &conflict)
+		assert.Equal(t, "manager", conflict.Expected)
+		assert.Equal(t, "admin", conflict.Actual)
+		assert.Contains(t, err.Error(), "manager")
+		assert.Contains(t, err.Error(), "admin")
+		assert.Equal(t, codes.Aborted, status.Code(err))
+		assert.Equal(t, 1, writes)
+	})
+}
+
+// TestResolveAPIURL tests looking up the API URL of a company's data center.
+func TestResolveAPIURL(t *testing.T) {
+	lookups := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups++
+		assert.Equal(t, http.MethodPost, r.Method)
+		var req AccountConfigRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		if req.CompanyName != "acme" {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(ZuperError{Type: "error", MessageError: "Company not found"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(AccountConfigResponse{
+			Type:   "success",
+			Config: AccountConfig{DcName: "us-east-1", DcApiUrl: "https://us-east-1.zuperpro.com/api"},
+		})
+	}))
+	defer server.Close()
+
+	previous := accountsURL
+	accountsURL = server.URL
+	defer func() { accountsURL = previous }()
+	ctx := context.Background()
+	httpClient, err := NewHTTPClient(ctx, TransportOptions{})
+	assert.NoError(t, err)
+
+	apiURL, err := ResolveAPIURL(ctx, "", "acme", httpClient)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://us-east-1.zuperpro.com", apiURL)
+
+	apiURL, err = ResolveAPIURL(ctx, "https://us-east-1.zuperpro.com/", "acme", httpClient)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://us-east-1.zuperpro.com/", apiURL)
+	assert.Equal(t, 2, lookups)
+
+	_, err = ResolveAPIURL(ctx, "https://eu-west-1.zuperpro.com", "acme", httpClient)
+	assert.ErrorContains(t, err, "does not match https://us-east-1.zuperpro.com")
+
+	_, err = ResolveAPIURL(ctx, "", "unknown", httpClient)
+	assert.Error(t, err)
+
+	apiURL, err = ResolveAPIURL(ctx, "https://eu-west-1.zuperpro.com", "", httpClient)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://eu-west-1.zuperpro.com", apiURL)
+}
+
+// TestAPIKeyRotation tests that a rejected API key is reloaded from its source and the request retried.
+func TestAPIKeyRotation(t *testing.T) {
+	validKey := "key-2"
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("x-api-key"))
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("x-api-key") != validKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(ZuperError{Type: "error", MessageError: "Invalid API key"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(UserDetailsResponse{Type: "success", Data: ZuperUser{UserUID: "user-1"}})
+	}))
+	defer server.Close()
+
+	keyFile := filepath.Join(t.TempDir(), "api-key")
+	assert.NoError(t, os.WriteFile(keyFile, []byte("key-2\n"), 0o600))
+
+	ctx := context.Background()
+	httpClient, _ := uhttp.NewBaseHttpClientWithContext(ctx, &http.Client{})
+	client := NewClient(ctx, server.URL, "key-1", httpClient)
+	client.SetAPIKeySource(APIKeyFromFile(keyFile))
+
+	user, _, err := client.GetUserByID(ctx, "user-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1", user.UserUID)
+	assert.Equal(t, []string{"key-1", "key-2"}, received)
+
+	t.Run("no retry when the key did not change", func(t *testing.T) {
+		received = nil
+		validKey = "key-3"
+		_, _, err := client.GetUserByID(WithoutCache(ctx), "user-1")
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		assert.Equal(t, []string{"key-2"}, received)
+	})
+
+	t.Run("key from command", func(t *testing.T) {
+		key, err := APIKeyFromCommand("echo key-3")(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "key-3", key)
+
+		_, err = APIKeyFromCommand("exit 1")(ctx)
+		assert.Error(t, err)
+	})
+}
+
+// TestWithoutCache tests that reads bypassing the cache reach Zuper and report errors the same way as cached reads.
+func TestWithoutCache(t *testing.T) {
+	var reads int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/user/user-1":
+			reads++
+			_ = json.NewEncoder(w).Encode(UserDetailsResponse{Type: "success", Data: ZuperUser{UserUID: "user-1"}})
+		case "/api/user/html":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte("<html></html>"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(ZuperError{Type: "error", MessageError: "User not found"})
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+	t.Setenv(disableCacheEnv, "false")
+	client := mustNew(t, server.URL, TransportOptions{})
+	assert.Equal(t, "false", os.Getenv(disableCacheEnv), "building the uncached client restores the environment")
+
+	for i := 0; i < 2; i++ {
+		_, _, err := client.GetUserByID(ctx, "user-1")
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 1, reads, "the second read is served from the cache")
+	for i := 0; i < 2; i++ {
+		user, _, err := client.GetUserByID(WithoutCache(ctx), "user-1")
+		assert.NoError(t, err)
+		assert.Equal(t, "user-1", user.UserUID)
+	}
+	assert.Equal(t, 3, reads, "reads without the cache always reach Zuper")
+
+	for _, userID := range []string{"missing", "html"} {
+		_, _, cachedErr := client.GetUserByID(ctx, userID)
+		_, _, freshErr := client.GetUserByID(WithoutCache(ctx), userID)
+		assert.Error(t, freshErr)
+		assert.Equal(t, status.Code(cachedErr), status.Code(freshErr), userID)
+		assert.Equal(t, cachedErr.Error(), freshErr.Error(), userID)
+	}
+	_, _, err := client.GetUserByID(WithoutCache(ctx), "missing")
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Contains(t, err.Error(), "User not found")
+}
+
+// TestTransportOptions tests that the CA bundle, proxy and timeouts apply to the client's requests.
+func TestTransportOptions(t *testing.T) {
+	ctx := context.Background()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(UserDetailsResponse{Type: "success", Data: ZuperUser{UserUID: "user-1"}})
+	})
+
+	t.Run("CA bundle", func(t *testing.T) {
+		server := httptest.NewTLSServer(handler)
+		defer server.Close()
+
+		_, _, err := mustNew(t, server.URL, TransportOptions{}).GetUserByID(ctx, "user-1")
+		assert.Error(t, err, "the test server's certificate is not trusted by default")
+
+		bundle := filepath.Join(t.TempDir(), "ca.pem")
+		certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+		assert.NoError(t, os.WriteFile(bundle, certPEM, 0o600))
+		user, _, err := mustNew(t, server.URL, TransportOptions{CABundle: bundle}).GetUserByID(ctx, "user-1")
+		assert.NoError(t, err)
+		assert.Equal(t, "user-1", user.UserUID)
+	})
+
+	t.Run("proxy", func(t *testing.T) {
+		var proxied string
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			proxied = r.URL.String()
+			handler(w, r)
+		}))
+		defer proxy.Close()
+
+		_, _, err := mustNew(t, "http://zuper.invalid", TransportOptions{ProxyURL: proxy.URL}).GetUserByID(ctx, "user-1")
+		assert.NoError(t, err)
+		assert.Equal(t, "http://zuper.invalid/api/user/user-1", proxied)
+	})
+
+	t.Run("request logging", func(t *testing.T) {
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		var logs strings.Builder
+		core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(&logs), zapcore.DebugLevel)
+		logCtx := ctxzap.ToContext(ctx, zap.New(core))
+		httpClient, err := NewHTTPClient(logCtx, TransportOptions{})
+		assert.NoError(t, err)
+		_, _, err = NewClient(logCtx, server.URL, "dummy-token", httpClient).GetUserByID(logCtx, "user-1")
+		assert.NoError(t, err)
+		assert.Contains(t, logs.String(), `"msg":"Request complete"`)
+		assert.Contains(t, logs.String(), `"http.url_details.path":"/api/user/user-1"`)
+		assert.Contains(t, logs.String(), `"http.status_code":200`)
+	})
+
+	t.Run("response timeout", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+			handler(w, r)
+		}))
+		defer server.Close()
+
+		_, _, err := mustNew(t, server.URL, TransportOptions{ResponseTimeout: 20 * time.Millisecond}).GetUserByID(ctx, "user-1")
+		assert.Error(t, err)
+	})
+
+	t.Run("stalled body", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"type":"success","data":`))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}))
+		defer server.Close()
+
+		start := time.Now()
+		_, _, err := mustNew(t, server.URL, TransportOptions{Timeout: 50 * time.Millisecond}).GetUserByID(ctx, "user-1")
+		assert.Error(t, err)
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("cross-host redirect", func(t *testing.T) {
+		var leaked string
+		other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			leaked = r.Header.Get("x-api-key")
+			handler(w, r)
+		}))
+		defer other.Close()
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, strings.Replace(other.URL, "127.0.0.1", "localhost", 1)+r.URL.Path, http.StatusFound)
+		}))
+		defer server.Close()
+
+		_, _, err := mustNew(t, server.URL, TransportOptions{}).GetUserByID(ctx, "user-1")
+		assert.ErrorContains(t, err, "refusing redirect")
+		assert.Empty(t, leaked, "the API key is not sent to another host")
+	})
+
+	t.Run("invalid client certificate", func(t *testing.T) {
+		_, err := New(ctx, "https://zuper.invalid", "dummy-token", TransportOptions{ClientCert: "missing.pem", ClientKey: "missing.key"})
+		assert.ErrorContains(t, err, "failed to load client certificate")
+	})
+}
+
+// mustNew returns a client created with New for the given API URL and transport options.
+func mustNew(t *testing.T, apiURL string, transport TransportOptions) *Client {
+	client, err := New(context.Background(), apiURL, "dummy-token", transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// TestRateLimiter tests request pacing, the in-flight cap and adapting to the quota Zuper reports.
+func TestRateLimiter(t *testing.T) {
+	t.Run("token bucket", func(t *testing.T) {
+		limiter := newRateLimiter(RateLimitOptions{RequestsPerSecond: 2})
+		now := limiter.last
+		assert.Zero(t, limiter.reserve(now))
+		assert.Zero(t, limiter.reserve(now))
+		assert.Equal(t, 500*time.Millisecond, limiter.reserve(now))
+		assert.Zero(t, limiter.reserve(now.Add(500*time.Millisecond)))
+	})
+
+	t.Run("in-flight cap", func(t *testing.T) {
+		limiter := newRateLimiter(RateLimitOptions{MaxInFlight: 1})
+		release, err := limiter.wait(context.Background())
+		assert.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err = limiter.wait(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		release()
+		release, err = limiter.wait(context.Background())
+		assert.NoError(t, err)
+		release()
+	})
+
+	t.Run("adapts to the reported quota", func(t *testing.T) {
+		limiter := newRateLimiter(RateLimitOptions{RequestsPerSecond: 5, QuotaShare: 0.8})
+		now := time.Now()
+		quota := func(remaining int64) *v2.RateLimitDescription {
+			return &v2.RateLimitDescription{
+				Status:    v2.RateLimitDescription_STATUS_OK,
+				Limit:     100,
+				Remaining: remaining,
+				ResetAt:   timestamppb.New(now.Add(10 * time.Second)),
+			}
+		}
+
+		limiter.observe(context.Background(), quota(90), now)
+		assert.Equal(t, 5.0, limiter.rate, "the configured rate caps the adapted rate")
+
+		limiter.observe(context.Background(), quota(30), now)
+		assert.InDelta(t, 1.0, limiter.rate, 0.001, "20 of the remaining 30 requests are left to others")
+
+		limiter.observe(context.Background(), quota(15), now)
+		assert.Equal(t, 10*time.Second, limiter.reserve(now), "requests pause until the quota resets")
+	})
+
+	t.Run("nil limiter", func(t *testing.T) {
+		var limiter *rateLimiter
+		release, err := limiter.wait(context.Background())
+		assert.NoError(t, err)
+		release()
+	})
+}
+
+// TestCircuitBreaker tests that the breaker opens after consecutive 5xx responses, fails fast while open and lets
+// a single probe close it again.
+func TestCircuitBreaker(t *testing.T) {
+	var requests atomic.Int32
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"type":"error","message":"maintenance"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"type":"success","data":{"user_uid":"u1"}}`))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client := mustNew(t, server.URL, TransportOptions{})
+	client.SetCircuitBreaker(CircuitBreakerOptions{Threshold: 2, CoolDown: 50 * time.Millisecond})
+
+	for i := 0; i < 2; i++ {
+		_, _, err := client.GetUserByID(ctx, "u1")
+		assert.Error(t, err)
+	}
+	assert.Equal(t, breakerOpen, client.breaker.state)
+
+	_, _, err := client.GetUserByID(ctx, "u1")
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Contains(t, err.Error(), "Zuper unavailable")
+	assert.Equal(t, int32(2), requests.Load(), "requests fail fast while the breaker is open")
+
+	time.Sleep(60 * time.Millisecond)
+	_, _, err = client.GetUserByID(ctx, "u1")
+	assert.Error(t, err)
+	assert.Equal(t, int32(3), requests.Load(), "a probe is sent after the cool-down")
+	assert.Equal(t, breakerOpen, client.breaker.state, "a failed probe opens the breaker again")
+
+	healthy.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	user, _, err := client.GetUserByID(ctx, "u1")
+	assert.NoError(t, err)
+	assert.Equal(t, "u1", user.UserUID)
+	assert.Equal(t, breakerClosed, client.breaker.state, "a successful probe closes the breaker")
+}
+
+// TestCircuitBreakerCancelledProbe tests that a probe given up while waiting for the rate limiter frees the probe
+// slot without closing the breaker.
+func TestCircuitBreakerCancelledProbe(t *testing.T) {
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"type":"error","message":"maintenance"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"type":"success","data":{"user_uid":"u1"}}`))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client := mustNew(t, server.URL, TransportOptions{})
+	client.SetCircuitBreaker(CircuitBreakerOptions{Threshold: 1, CoolDown: 10 * time.Millisecond})
+	client.SetRateLimit(RateLimitOptions{MaxInFlight: 1})
+
+	_, _, err := client.GetUserByID(ctx, "u1")
+	assert.Error(t, err)
+	assert.Equal(t, breakerOpen, client.breaker.state)
+	time.Sleep(20 * time.Millisecond)
+
+	release, err := client.limiter.wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, _, err = client.GetUserByID(waitCtx, "u1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	release()
+	assert.Equal(t, breakerHalfOpen, client.breaker.state, "a cancelled probe leaves the breaker half-open")
+	assert.False(t, client.breaker.probing, "a cancelled probe frees the probe slot")
+
+	healthy.Store(true)
+	_, _, err = client.GetUserByID(ctx, "u1")
+	assert.NoError(t, err)
+	assert.Equal(t, breakerClosed, client.breaker.state)
+}
+
+// spanRecorder keeps the spans ended through a tracer provider.
+type spanRecorder struct {
+	sdktrace.SpanProcessor
+	mu    sync.Mutex
+	spans []sdktrace.ReadOnlySpan
+}
+
+func (r *spanRecorder) OnEnd(span sdktrace.ReadOnlySpan) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, span)
+}
+
+// metricRecorder is a metrics.Handler adding up the values recorded for each metric.
+type metricRecorder struct {
+	mu     sync.Mutex
+	values map[string]int64
+}
+
+func (r *metricRecorder) add(name string, value int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values[name] += value
+}
+
+func (r *metricRecorder) Int64Counter(name string, _ string, _ metrics.Unit) metrics.Int64Counter {
+	return recordedMetric{r, name}
+}
+
+func (r *metricRecorder) Int64Gauge(name string, _ string, _ metrics.Unit) metrics.Int64Gauge {
+	return recordedMetric{r, name}
+}
+
+func (r *metricRecorder) Int64Histogram(name string, _ string, _ metrics.Unit) metrics.Int64Histogram {
+	return recordedMetric{r, name + "_count"}
+}
+
+func (r *metricRecorder) WithTags(map[string]string) metrics.Handler {
+	return r
+}
+
+type recordedMetric struct {
+	recorder *metricRecorder
+	name     string
+}
+
+func (m recordedMetric) Add(_ context.Context, value int64, _ map[string]string) {
+	m.recorder.add(m.name, value)
+}
+
+func (m recordedMetric) Observe(_ context.Context, value int64, _ map[string]string) {
+	m.recorder.add(m.name, value)
+}
+
+func (m recordedMetric) Record(_ context.Context, _ int64, _ map[string]string) {
+	m.recorder.add(m.name, 1)
+}
+
+// TestTelemetry tests the span and metrics recorded for a Zuper API call.
+func TestTelemetry(t *testing.T) {
+	recorder := &spanRecorder{SpanProcessor: sdktrace.NewSimpleSpanProcessor(nil)}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if healthy.Load() {
+			_, _ = w.Write([]byte(`{"type":"success","data":[{"user_uid":"u1"},{"user_uid":"u2"}],"current_page":1,"total_pages":2}`))
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"type":"error","message":"boom"}`))
+	}))
+	defer server.Close()
+
+	client := mustNew(t, server.URL, TransportOptions{})
+	handler := &metricRecorder{values: make(map[string]int64)}
+	client.telemetry = newTelemetry(handler)
+
+	_, _, _, err := client.GetUsers(context.Background(), PageOptions{PageToken: getNextToken(1, 3)})
+	assert.Error(t, err)
+
+	assert.Len(t, recorder.spans, 1)
+	span := recorder.spans[0]
+	assert.Equal(t, "GET /api/user/all", span.Name())
+	assert.Equal(t, otelcodes.Error, span.Status().Code)
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, attr := range span.Attributes() {
+		attrs[attr.Key] = attr.Value
+	}
+	assert.Equal(t, "GET", attrs[attrMethod].AsString())
+	assert.Equal(t, "/api/user/all", attrs[attrEndpoint].AsString())
+	assert.Equal(t, int64(2), attrs[attrPage].AsInt64())
+	assert.Equal(t, int64(http.StatusInternalServerError), attrs[attrStatusCode].AsInt64())
+	assert.Equal(t, int64(0), attrs[attrRetryCount].AsInt64())
+
+	assert.Equal(t, map[string]int64{
+		"zuper_request_duration_count": 1,
+		"zuper_request_errors":         1,
+		"zuper_rate_limit_wait_count":  1,
+	}, handler.values)
+
+	// Breaking out of an iterator stops the page early, which is not a failed request.
+	healthy.Store(true)
+	for user, err := range client.AllUsers(context.Background(), nil) {
+		assert.NoError(t, err)
+		assert.Equal(t, "u1", user.UserUID)
+		break
+	}
+	assert.Len(t, recorder.spans, 2)
+	assert.NotEqual(t, otelcodes.Error, recorder.spans[1].Status().Code)
+	assert.Empty(t, recorder.spans[1].Events(), "no error is recorded on the span")
+	assert.Equal(t, int64(1), handler.values["zuper_request_errors"])
+
+	assert.Equal(t, "/api/team/{id}/users", endpointTemplate("/api/team/c3dea3e3-8bc3-459f-aaeb-04fd6f501fa5/users"))
+	assert.Equal(t, "/api/user/{id}", endpointTemplate("/api/user/42"))
+}
+
+// TestPageDecoder tests handing the items of a page to a callback as they are decoded.
+func TestPageDecoder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/user/all":
+			_, _ = w.Write([]byte(`{"type":"success","total_records":3,"data":[
+				{"user_uid":"u1","access_role":{"access_role_uid":"a1"}},
+				{"user_uid":"u2","role":null},
+				{"user_uid":"u3"}
+			],"current_page":1,"total_pages":2}`))
+		case "/api/team/t1":
+			_, _ = w.Write([]byte(`{"type":"success","data":{"team":{"team_uid":"t1"},"users":[{"user_uid":"u1"},{"user_uid":"u3"}]}}`))
+		default:
+			_, _ = w.Write([]byte(`{"type":"success","data":null}`))
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+	client := mustNew(t, server.URL, TransportOptions{})
+
+	var uids []string
+	nextToken, _, err := client.EachUser(ctx, PageOptions{}, func(user *ZuperUser) error {
+		uids = append(uids, user.UserUID)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"u1", "u2", "u3"}, uids)
+	assert.Equal(t, getNextToken(1, 2), nextToken)
+
+	stop := errors.New("stop")
+	seen := 0
+	_, _, err = client.EachUser(ctx, PageOptions{}, func(*ZuperUser) error {
+		seen++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, seen, "decoding stops at the first callback error")
+
+	members, _, _, err := client.GetTeamUsers(ctx, "t1")
+	assert.NoError(t, err)
+	assert.Len(t, members, 2)
+	assert.Equal(t, "u3", members[1].UserUID)
+
+	teams, nextToken, _, err := client.GetTeams(ctx, PageOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, teams)
+	assert.Empty(t, nextToken)
+
+	// Callbacks run once the page request has given back its in-flight slot, so they may send requests too.
+	client.SetRateLimit(RateLimitOptions{MaxInFlight: 1})
+	nested, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, _, err = client.EachUser(WithoutCache(nested), PageOptions{}, func(*ZuperUser) error {
+		_, _, _, err := client.GetTeamUsers(nested, "t1")
+		return err
+	})
+	assert.NoError(t, err)
+}
+
+// TestIterators tests following page tokens, stopping early, cancellation and errors in the client iterators.
+func TestIterators(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		page := r.URL.Query().Get("page")
+		w.Header().Set("X-Ratelimit-Limit", "100")
+		w.Header().Set("X-Ratelimit-Remaining", "9"+page)
+		switch r.URL.Path {
+		case "/api/user/all":
+			_, _ = fmt.Fprintf(w, `{"data":[{"user_uid":"u%[1]s-1"},{"user_uid":"u%[1]s-2"}],"current_page":%[1]s,"total_pages":2}`, page)
+		case "/api/teams/summary":
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"type":"error","message":"forbidden"}`))
+		case "/api/team/t1":
+			_, _ = w.Write([]byte(`{"data":{"users":[{"user_uid":"u1-1"}]}}`))
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+	client := mustNew(t, server.URL, TransportOptions{})
+
+	t.Run("follows page tokens", func(t *testing.T) {
+		var annos annotations.Annotations
+		var uids []string
+		for user, err := range client.AllUsers(ctx, &annos) {
+			assert.NoError(t, err)
+			uids = append(uids, user.UserUID)
+		}
+		assert.Equal(t, []string{"u1-1", "u1-2", "u2-1", "u2-2"}, uids)
+		rateLimit := &v2.RateLimitDescription{}
+		ok, err := annos.Pick(rateLimit)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, int64(92), rateLimit.GetRemaining(), "the rate limit of the last page is kept")
+	})
+
+	t.Run("stops fetching when the loop breaks", func(t *testing.T) {
+		before := requests.Load()
+		for range client.AllUsers(WithoutCache(ctx), nil) {
+			break
+		}
+		assert.Equal(t, before+1, requests.Load())
+	})
+
+	t.Run("context cancellation", func(t *testing.T) {
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		for user, err := range client.AllUsers(cancelled, nil) {
+			assert.Nil(t, user)
+			assert.ErrorIs(t, err, context.Canceled)
+		}
+	})
+
+	t.Run("errors end the iteration", func(t *testing.T) {
+		count := 0
+		for team, err := range client.AllTeams(ctx, nil) {
+			count++
+			assert.Nil(t, team)
+			assert.Equal(t, codes.PermissionDenied, status.Code(err))
+		}
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("team members", func(t *testing.T) {
+		inTeam, err := client.IsUserInTeam(ctx, "t1", "u1-1")
+		assert.NoError(t, err)
+		assert.True(t, inTeam)
+	})
+}
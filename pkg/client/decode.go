@@ -0,0 +1,103 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// errDecodeStopped stops decoding a page once its callback returns an error.
+var errDecodeStopped = errors.New("page decoding stopped")
+
+// pageDecoder decodes a page of a Zuper list response one item at a time, handing each item to a callback instead
+// of building a slice of the whole array. It is not a streaming decoder: the HTTP wrapper reads the response body in
+// full first, so the body of a page stays in memory while its items are decoded. path holds the keys leading from
+// the top-level object to the array.
+type pageDecoder[T any] struct {
+	path []string
+	each func(*T) error
+
+	CurrentPage int
+	TotalPages  int
+	// err is the error returned by each, which stopped the decoding.
+	err error
+}
+
+// newPageDecoder returns a pageDecoder calling each for every item of the array found under path.
+func newPageDecoder[T any](each func(*T) error, path ...string) *pageDecoder[T] {
+	return &pageDecoder[T]{path: path, each: each}
+}
+
+// UnmarshalJSON implements json.Unmarshaler by walking the response tokens down to the item array.
+func (s *pageDecoder[T]) UnmarshalJSON(data []byte) error {
+	return s.decodeObject(json.NewDecoder(bytes.NewReader(data)), 0)
+}
+
+// decodeObject decodes the object at depth in path, descending into the key leading to the item array.
+func (s *pageDecoder[T]) decodeObject(dec *json.Decoder, depth int) error {
+	isNull, err := openDelim(dec, '{')
+	if err != nil || isNull {
+		return err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		switch {
+		case key == s.path[depth] && depth == len(s.path)-1:
+			err = s.decodeItems(dec)
+		case key == s.path[depth]:
+			err = s.decodeObject(dec, depth+1)
+		case depth == 0 && key == "current_page":
+			err = dec.Decode(&s.CurrentPage)
+		case depth == 0 && key == "total_pages":
+			err = dec.Decode(&s.TotalPages)
+		default:
+			var skipped json.RawMessage
+			err = dec.Decode(&skipped)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token()
+	return err
+}
+
+// decodeItems decodes the item array, handing each item to the callback as soon as it is decoded.
+func (s *pageDecoder[T]) decodeItems(dec *json.Decoder) error {
+	isNull, err := openDelim(dec, '[')
+	if err != nil || isNull {
+		return err
+	}
+	for dec.More() {
+		var item T
+		if err := dec.Decode(&item); err != nil {
+			return err
+		}
+		if err := s.each(&item); err != nil {
+			s.err = err
+			return errDecodeStopped
+		}
+	}
+	_, err = dec.Token()
+	return err
+}
+
+// openDelim reads the opening delimiter of an object or array, reporting whether the value is null instead.
+func openDelim(dec *json.Decoder, delim json.Delim) (bool, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return false, err
+	}
+	if tok == nil {
+		return true, nil
+	}
+	if tok != delim {
+		return false, fmt.Errorf("expected %s in page response, got %v", delim, tok)
+	}
+	return false, nil
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// accountsURL is Zuper's account-config lookup, which returns the data center of a company login name.
+var accountsURL = "https://accounts.zuperpro.com/api/config"
+
+// ResolveAPIURL returns the API URL of a Zuper tenant. When companyName is set, the URL of the tenant's
+// data center is looked up through httpClient and, if apiUrl is also set, must match it. Otherwise apiUrl is
+// returned unchanged.
+func ResolveAPIURL(ctx context.Context, apiUrl string, companyName string, httpClient *uhttp.BaseHttpClient) (string, error) {
+	if companyName == "" {
+		return apiUrl, nil
+	}
+	discovered, err := discoverAPIURL(ctx, companyName, httpClient)
+	if err != nil {
+		return "", err
+	}
+	if apiUrl == "" {
+		return discovered, nil
+	}
+	if !sameAPIURL(apiUrl, discovered) {
+		return "", fmt.Errorf("api-url %s does not match %s, the data center of company %s", apiUrl, discovered, companyName)
+	}
+	return apiUrl, nil
+}
+
+// discoverAPIURL looks up the API URL of the data center hosting a company.
+func discoverAPIURL(ctx context.Context, companyName string, wrapper *uhttp.BaseHttpClient) (string, error) {
+	lookupURL, err := url.Parse(accountsURL)
+	if err != nil {
+		return "", err
+	}
+	req, err := wrapper.NewRequest(ctx, http.MethodPost, lookupURL,
+		uhttp.WithContentTypeJSONHeader(),
+		uhttp.WithAcceptJSONHeader(),
+		uhttp.WithJSONBody(AccountConfigRequest{CompanyName: companyName}),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	var configResp AccountConfigResponse
+	var zuperErr ZuperError
+	resp, err := wrapper.Do(req, uhttp.WithJSONResponse(&configResp), uhttp.WithErrorResponse(&zuperErr))
+	if err != nil {
+		return "", fmt.Errorf("failed to look up the data center of company %s: %w", companyName, err)
+	}
+	defer resp.Body.Close()
+
+	apiURL := strings.TrimSuffix(strings.TrimSuffix(configResp.Config.DcApiUrl, "/"), "/api")
+	if apiURL == "" {
+		return "", fmt.Errorf("no data center found for company %s", companyName)
+	}
+	ctxzap.Extract(ctx).Info("discovered Zuper API URL",
+		zap.String("company_name", companyName),
+		zap.String("api_url", apiURL),
+	)
+	return apiURL, nil
+}
+
+// sameAPIURL reports whether two API URLs point at the same host, ignoring a trailing /api path.
+func sameAPIURL(a, b string) bool {
+	parsedA, errA := url.Parse(a)
+	parsedB, errB := url.Parse(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return strings.EqualFold(parsedA.Host, parsedB.Host)
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// SetDryRun enables or disables dry-run mode. In dry-run mode the client performs reads as usual
+// but only logs and annotates the requests it would send for mutations.
+func (c *Client) SetDryRun(enabled bool) {
+	c.dryRun = enabled
+}
+
+// isMutation reports whether a request with the given method changes data in Zuper.
+func isMutation(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	default:
+		return true
+	}
+}
+
+// dryRunRequest logs a mutation instead of sending it and returns it as an annotation
+// holding the HTTP method, URL and JSON body.
+func (c *Client) dryRunRequest(ctx context.Context, method string, requestURL string, body interface{}) (http.Header, annotations.Annotations, error) {
+	requestBody := ""
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to encode dry-run request body: %w", err)
+		}
+		requestBody = string(data)
+	}
+
+	ctxzap.Extract(ctx).Info("dry run: skipping request",
+		zap.String("method", method),
+		zap.String("url", requestURL),
+		zap.String("body", requestBody),
+	)
+
+	request, err := structpb.NewStruct(map[string]interface{}{
+		"dry_run": true,
+		"method":  method,
+		"url":     requestURL,
+		"body":    requestBody,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return http.Header{}, annotations.New(request), nil
+}
+
+// IsDryRun reports whether annos carry the annotation of a request that was skipped in dry-run mode.
+func IsDryRun(annos annotations.Annotations) bool {
+	request := &structpb.Struct{}
+	ok, err := annos.Pick(request)
+	if err != nil || !ok {
+		return false
+	}
+	return request.GetFields()["dry_run"].GetBoolValue()
+}
@@ -0,0 +1,28 @@
+package client
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ConflictError reports that a user field changed in Zuper between the caller's read and its write.
+type ConflictError struct {
+	UserUID           string
+	Field             string
+	Expected          interface{}
+	Actual            interface{}
+	ExpectedUpdatedAt string
+	ActualUpdatedAt   string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflict updating %s of user %s: expected %v (updated_at %q), found %v (updated_at %q)",
+		e.Field, e.UserUID, e.Expected, e.ExpectedUpdatedAt, e.Actual, e.ActualUpdatedAt)
+}
+
+// GRPCStatus reports the conflict as Aborted, so the operation is retried against fresh state.
+func (e *ConflictError) GRPCStatus() *status.Status {
+	return status.New(codes.Aborted, e.Error())
+}
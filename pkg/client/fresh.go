@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+)
+
+// disableCacheEnv is the environment variable the SDK reads to build HTTP wrappers without a response cache.
+const disableCacheEnv = "BATON_DISABLE_HTTP_CACHE"
+
+// cacheEnvMu serializes the changes newUncachedWrapper makes to disableCacheEnv.
+var cacheEnvMu sync.Mutex
+
+// skipCacheKey is the context key marking reads that must bypass the HTTP cache.
+type skipCacheKey struct{}
+
+// WithoutCache returns a context whose reads go to Zuper instead of the HTTP response cache. Provisioning uses it
+// to decide on and verify changes against the current state rather than what an earlier sync saw.
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipCacheKey{}, true)
+}
+
+// skipCache reports whether reads in ctx must bypass the HTTP cache.
+func skipCache(ctx context.Context) bool {
+	skip, _ := ctx.Value(skipCacheKey{}).(bool)
+	return skip
+}
+
+// newUncachedWrapper returns an SDK HTTP wrapper sending requests with httpClient and no response cache, which
+// serves the reads that bypass the cache. The SDK only takes its cache settings from the environment, so the cache
+// is disabled there while the wrapper is built.
+func newUncachedWrapper(ctx context.Context, httpClient *http.Client) (*uhttp.BaseHttpClient, error) {
+	cacheEnvMu.Lock()
+	defer cacheEnvMu.Unlock()
+	previous, wasSet := os.LookupEnv(disableCacheEnv)
+	if err := os.Setenv(disableCacheEnv, "true"); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if wasSet {
+			_ = os.Setenv(disableCacheEnv, previous)
+		} else {
+			_ = os.Unsetenv(disableCacheEnv)
+		}
+	}()
+	return uhttp.NewBaseHttpClientWithContext(ctx, httpClient)
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"iter"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+)
+
+// errStopIteration stops fetching pages once the consumer of an iterator breaks out of its loop.
+var errStopIteration = errors.New("iteration stopped")
+
+// pageFetcher fetches the page at token, handing each item to fn, and returns the token of the next page.
+type pageFetcher[T any] func(ctx context.Context, token string, fn func(*T) error) (string, annotations.Annotations, error)
+
+// AllUsers iterates over every user of the tenant, fetching pages as the loop needs them. If annos is not nil, it
+// is updated with the rate limit reported by the last page fetched. An error ends the iteration.
+func (c *Client) AllUsers(ctx context.Context, annos *annotations.Annotations) iter.Seq2[*ZuperUser, error] {
+	return paginate(ctx, annos, func(ctx context.Context, token string, fn func(*ZuperUser) error) (string, annotations.Annotations, error) {
+		return c.EachUser(ctx, PageOptions{PageToken: token, PageSize: DefaultPageSize}, fn)
+	})
+}
+
+// AllTeams iterates over every team of the tenant, fetching pages as the loop needs them. If annos is not nil, it
+// is updated with the rate limit reported by the last page fetched. An error ends the iteration.
+func (c *Client) AllTeams(ctx context.Context, annos *annotations.Annotations) iter.Seq2[*Team, error] {
+	return paginate(ctx, annos, func(ctx context.Context, token string, fn func(*Team) error) (string, annotations.Annotations, error) {
+		return c.EachTeam(ctx, PageOptions{PageToken: token, PageSize: DefaultPageSize}, fn)
+	})
+}
+
+// AllTeamMembers iterates over the users of a team. If annos is not nil, it is updated with the rate limit
+// reported by Zuper. An error ends the iteration.
+func (c *Client) AllTeamMembers(ctx context.Context, teamID string, annos *annotations.Annotations) iter.Seq2[*ZuperUser, error] {
+	return paginate(ctx, annos, func(ctx context.Context, _ string, fn func(*ZuperUser) error) (string, annotations.Annotations, error) {
+		pageAnnos, err := c.EachTeamMember(ctx, teamID, fn)
+		return "", pageAnnos, err
+	})
+}
+
+// paginate returns an iterator over the items of the pages returned by fetch, following page tokens until the
+// last page. It checks ctx before every page and yields the first error it meets as the last element.
+func paginate[T any](ctx context.Context, annos *annotations.Annotations, fetch pageFetcher[T]) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		token := ""
+		for {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+			nextToken, pageAnnos, err := fetch(ctx, token, func(item *T) error {
+				if !yield(item, nil) {
+					return errStopIteration
+				}
+				return nil
+			})
+			if errors.Is(err, errStopIteration) {
+				return
+			}
+			updateRateLimit(annos, pageAnnos)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if nextToken == "" {
+				return
+			}
+			token = nextToken
+		}
+	}
+}
+
+// updateRateLimit copies the rate limit annotation of a page, if any, into annos.
+func updateRateLimit(annos *annotations.Annotations, pageAnnos annotations.Annotations) {
+	if annos == nil {
+		return
+	}
+	rateLimit := &v2.RateLimitDescription{}
+	if ok, err := pageAnnos.Pick(rateLimit); err == nil && ok {
+		annos.Update(rateLimit)
+	}
+}
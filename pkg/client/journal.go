@@ -0,0 +1,264 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/metadata"
+)
+
+// redacted replaces secret values written to the journal.
+const redacted = "[REDACTED]"
+
+// correlationIDKey is the context key holding the correlation ID of a request.
+type correlationIDKey struct{}
+
+// correlationIDHeaders are the incoming gRPC metadata keys checked for a correlation ID.
+var correlationIDHeaders = []string{"x-correlation-id", "x-request-id"}
+
+// WithCorrelationID returns a context carrying the correlation ID recorded in the audit journal.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationID returns the correlation ID of the request context, taken from WithCorrelationID or,
+// failing that, from the incoming request metadata.
+func CorrelationID(ctx context.Context) string {
+	if id, ok := ctx.Value(correlationIDKey{}).(string); ok && id != "" {
+		return id
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, key := range correlationIDHeaders {
+			if values := md.Get(key); len(values) > 0 && values[0] != "" {
+				return values[0]
+			}
+		}
+	}
+	return ""
+}
+
+// JournalEntry is a single line of the audit journal, describing one mutating call.
+type JournalEntry struct {
+	Timestamp     time.Time   `json:"timestamp"`
+	Operation     string      `json:"operation"`
+	CorrelationID string      `json:"correlation_id,omitempty"`
+	UserUID       string      `json:"user_uid,omitempty"`
+	TargetType    string      `json:"target_type,omitempty"`
+	TargetUID     string      `json:"target_uid,omitempty"`
+	Field         string      `json:"field,omitempty"`
+	OldValue      interface{} `json:"old_value"`
+	NewValue      interface{} `json:"new_value"`
+	Message       string      `json:"message,omitempty"`
+	DryRun        bool        `json:"dry_run,omitempty"`
+	Error         string      `json:"error,omitempty"`
+}
+
+// journal appends JournalEntry lines to a JSONL file.
+type journal struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// SetJournal enables the append-only audit journal at path, creating the file if needed.
+func (c *Client) SetJournal(path string) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit journal: %w", err)
+	}
+	c.journal = &journal{file: file}
+	return nil
+}
+
+// mutation describes a mutating call for the audit journal.
+type mutation struct {
+	operation  string
+	userUID    string
+	targetType string
+	targetUID  string
+	field      string
+	newValue   interface{}
+	// oldValue reads the current value before the change. It is only called when the journal is enabled.
+	oldValue func(ctx context.Context) (interface{}, error)
+}
+
+// journalBefore starts a journal entry for m, reading the value it is about to change.
+// It returns nil when the journal is disabled.
+func (c *Client) journalBefore(ctx context.Context, m mutation) *JournalEntry {
+	if c.journal == nil {
+		return nil
+	}
+	entry := &JournalEntry{
+		Operation:     m.operation,
+		CorrelationID: CorrelationID(ctx),
+		UserUID:       m.userUID,
+		TargetType:    m.targetType,
+		TargetUID:     m.targetUID,
+		Field:         m.field,
+		NewValue:      redact(m.field, m.newValue),
+		DryRun:        c.dryRun,
+	}
+	if m.oldValue != nil {
+		old, err := m.oldValue(WithoutCache(ctx))
+		if err != nil {
+			ctxzap.Extract(ctx).Warn("failed to read value before change for audit journal",
+				zap.String("operation", m.operation),
+				zap.Error(err),
+			)
+		}
+		entry.OldValue = redact(m.field, old)
+	}
+	return entry
+}
+
+// journalAfter completes a journal entry with the outcome of the call and appends it to the journal.
+// Failures to write the journal are logged rather than failing a change that has already been made.
+func (c *Client) journalAfter(ctx context.Context, entry *JournalEntry, message string, callErr error) {
+	if entry == nil {
+		return
+	}
+	entry.Timestamp = time.Now().UTC()
+	entry.Message = message
+	if callErr != nil {
+		entry.Error = callErr.Error()
+	}
+	if err := c.journal.append(entry); err != nil {
+		ctxzap.Extract(ctx).Error("failed to write audit journal",
+			zap.String("operation", entry.Operation),
+			zap.Error(err),
+		)
+	}
+}
+
+// append writes entry as a single JSON line.
+func (j *journal) append(entry *JournalEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, err = j.file.Write(append(data, '\n'))
+	return err
+}
+
+// isSecretKey reports whether a field name holds a secret.
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range []string{"password", "secret", "token", "api_key", "apikey"} {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// redact replaces secrets in a journal value. Structs are converted to their JSON form so that nested
+// secret fields, such as a new user's password, can be removed.
+func redact(field string, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	if isSecretKey(field) {
+		return redacted
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return value
+	}
+	return redactValue(generic)
+}
+
+// redactValue walks a decoded JSON value, replacing the values of secret keys.
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, nested := range v {
+			if isSecretKey(key) {
+				v[key] = redacted
+				continue
+			}
+			v[key] = redactValue(nested)
+		}
+		return v
+	case []interface{}:
+		for i, nested := range v {
+			v[i] = redactValue(nested)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// userFieldValue returns the current value of a user field as it is sent in an update.
+func userFieldValue(user *ZuperUser, field string) interface{} {
+	switch field {
+	case "role_id":
+		if user.Role == nil {
+			return nil
+		}
+		return user.Role.RoleUID
+	case "access_role":
+		if user.AccessRole == nil {
+			return nil
+		}
+		return user.AccessRole.AccessRoleUID
+	}
+	data, err := json.Marshal(user)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+	return fields[field]
+}
+
+// userHasSkill reports whether a user currently holds a skill.
+func (c *Client) userHasSkill(ctx context.Context, skillUID string, userUID string) (bool, error) {
+	holders, _, err := c.GetSkillUsers(ctx, skillUID)
+	if err != nil {
+		return false, err
+	}
+	for _, holder := range holders {
+		if holder.UserUID == userUID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// portalAccess returns whether a customer portal user currently has portal access.
+func (c *Client) portalAccess(ctx context.Context, customerUID string, portalUserUID string) (interface{}, error) {
+	pageToken := ""
+	for {
+		users, nextToken, _, err := c.GetCustomerPortalUsers(ctx, customerUID, PageOptions{
+			PageSize:  DefaultPageSize,
+			PageToken: pageToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			if user.PortalUserUID == portalUserUID {
+				return user.HasAccess, nil
+			}
+		}
+		if nextToken == "" {
+			return nil, nil
+		}
+		pageToken = nextToken
+	}
+}
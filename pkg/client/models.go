@@ -13,18 +13,27 @@ type PageOptions struct {
 
 // Users Models.
 type ZuperUser struct {
-	UserUID     string      `json:"user_uid"`
-	FirstName   string      `json:"first_name"`
-	LastName    string      `json:"last_name"`
-	Email       string      `json:"email"`
-	Designation string      `json:"designation"`
-	EmpCode     string      `json:"emp_code"`
-	IsActive    bool        `json:"is_active"`
-	IsDeleted   bool        `json:"is_deleted"`
-	CreatedAt   string      `json:"created_at"`
-	UpdatedAt   string      `json:"updated_at"`
-	Role        *Role       `json:"role"`
-	AccessRole  *AccessRole `json:"access_role"`
+	UserUID           string      `json:"user_uid"`
+	FirstName         string      `json:"first_name"`
+	LastName          string      `json:"last_name"`
+	Email             string      `json:"email"`
+	ExternalLoginID   string      `json:"external_login_id"`
+	Prefix            string      `json:"prefix"`
+	HomePhoneNumber   string      `json:"home_phone_number"`
+	WorkPhoneNumber   string      `json:"work_phone_number"`
+	MobilePhoneNumber string      `json:"mobile_phone_number"`
+	ProfilePicture    string      `json:"profile_picture"`
+	Designation       string      `json:"designation"`
+	EmpCode           string      `json:"emp_code"`
+	IsActive          bool        `json:"is_active"`
+	IsDeleted         bool        `json:"is_deleted"`
+	CreatedAt         string      `json:"created_at"`
+	UpdatedAt         string      `json:"updated_at"`
+	LastLoginAt       string      `json:"last_login_at"`
+	UserType          string      `json:"user_type"`
+	AccountExpiresAt  string      `json:"account_expires_at"`
+	Role              *Role       `json:"role"`
+	AccessRole        *AccessRole `json:"access_role"`
 }
 
 type UsersResponse struct {
@@ -40,6 +49,21 @@ type UserDetailsResponse struct {
 	Data ZuperUser `json:"data"`
 }
 
+// Account config lookup.
+type AccountConfigRequest struct {
+	CompanyName string `json:"company_name"`
+}
+
+type AccountConfigResponse struct {
+	Type   string        `json:"type"`
+	Config AccountConfig `json:"config"`
+}
+
+type AccountConfig struct {
+	DcName   string `json:"dc_name"`
+	DcApiUrl string `json:"dc_api_url"`
+}
+
 // Error Models.
 type ZuperError struct {
 	MessageError string `json:"message"`
@@ -58,13 +82,15 @@ type WorkHour struct {
 }
 
 type UserPayload struct {
-	FirstName   string `json:"first_name"`
-	LastName    string `json:"last_name"`
-	Email       string `json:"email"`
-	Password    string `json:"password"`
-	Designation string `json:"designation"`
-	EmpCode     string `json:"emp_code"`
-	RoleID      string `json:"role_id"`
+	FirstName        string `json:"first_name"`
+	LastName         string `json:"last_name"`
+	Email            string `json:"email"`
+	Password         string `json:"password"`
+	Designation      string `json:"designation"`
+	EmpCode          string `json:"emp_code"`
+	RoleID           string `json:"role_id"`
+	UserType         string `json:"user_type,omitempty"`
+	AccountExpiresAt string `json:"account_expires_at,omitempty"`
 }
 
 type CreateUserRequest struct {
@@ -94,6 +120,21 @@ type AccessRole struct {
 	RoleDescription string `json:"role_description"`
 }
 
+// ModulePermission lists the actions an access role allows on a Zuper module.
+type ModulePermission struct {
+	Module  string          `json:"module"`
+	Actions map[string]bool `json:"actions"`
+}
+
+type AccessRoleDetailsResponse struct {
+	Type string `json:"type"`
+	Data struct {
+		AccessRoleUID string             `json:"access_role_uid"`
+		RoleName      string             `json:"role_name"`
+		Permissions   []ModulePermission `json:"permissions"`
+	} `json:"data"`
+}
+
 // CreateUser models.
 type CreatedBy struct {
 	UserUID           string `json:"user_uid"`
@@ -150,6 +191,90 @@ type TeamDetailsWithUsersResponse struct {
 	} `json:"data"`
 }
 
+// Territory Models.
+type Territory struct {
+	TerritoryUID         string `json:"territory_uid"`
+	TerritoryName        string `json:"territory_name"`
+	TerritoryDescription string `json:"territory_description"`
+	TerritoryColor       string `json:"territory_color"`
+	UserCount            int    `json:"user_count"`
+	IsActive             bool   `json:"is_active"`
+	CreatedAt            string `json:"created_at"`
+	UpdatedAt            string `json:"updated_at"`
+}
+
+type TerritoriesResponse struct {
+	Type         string      `json:"type"`
+	Data         []Territory `json:"data"`
+	TotalRecords int         `json:"total_records"`
+	CurrentPage  int         `json:"current_page"`
+	TotalPages   int         `json:"total_pages"`
+}
+
+type TerritoryDetailsWithUsersResponse struct {
+	Type string `json:"type"`
+	Data struct {
+		Territory Territory   `json:"territory"`
+		Users     []ZuperUser `json:"users"`
+	} `json:"data"`
+}
+
+// AssignUserToTerritory models.
+type AssignUserToTerritoryRequest struct {
+	TerritoryUID string `json:"territory_uid"`
+	UserUID      string `json:"user_uid"`
+}
+
+type AssignUserToTerritoryResponse struct {
+	Type    string `json:"type"`
+	Title   string `json:"title"`
+	Message string `json:"message"`
+}
+
+// Skill Models.
+type Skill struct {
+	SkillUID         string `json:"skill_uid"`
+	SkillName        string `json:"skill_name"`
+	SkillDescription string `json:"skill_description"`
+	IsActive         bool   `json:"is_active"`
+	CreatedAt        string `json:"created_at"`
+	UpdatedAt        string `json:"updated_at"`
+}
+
+type SkillsResponse struct {
+	Type         string  `json:"type"`
+	Data         []Skill `json:"data"`
+	TotalRecords int     `json:"total_records"`
+	CurrentPage  int     `json:"current_page"`
+	TotalPages   int     `json:"total_pages"`
+}
+
+// UserSkill is a skill held by a user, with optional certification dates.
+type UserSkill struct {
+	UserUID     string `json:"user_uid"`
+	Email       string `json:"email"`
+	CertifiedAt string `json:"certified_at"`
+	ExpiresAt   string `json:"expires_at"`
+}
+
+type SkillUsersResponse struct {
+	Type string      `json:"type"`
+	Data []UserSkill `json:"data"`
+}
+
+// AssignSkillToUser models.
+type AssignSkillToUserRequest struct {
+	SkillUID  string `json:"skill_uid"`
+	UserUID   string `json:"user_uid"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+}
+
+type AssignSkillToUserResponse struct {
+	Type    string `json:"type"`
+	Title   string `json:"title"`
+	Message string `json:"message"`
+}
+
 // AssignUserToTeam models.
 type AssignUserToTeamRequest struct {
 	TeamUID string `json:"team_uid"`
@@ -187,3 +312,79 @@ type UpdateUserAccessRoleRequest struct {
 		AccessRole string `json:"access_role"`
 	} `json:"user"`
 }
+
+// Customer Models.
+type Customer struct {
+	CustomerUID         string `json:"customer_uid"`
+	CustomerFirstName   string `json:"customer_first_name"`
+	CustomerLastName    string `json:"customer_last_name"`
+	CustomerEmail       string `json:"customer_email"`
+	CustomerCompanyName string `json:"customer_company_name"`
+	IsActive            bool   `json:"is_active"`
+	CreatedAt           string `json:"created_at"`
+	UpdatedAt           string `json:"updated_at"`
+}
+
+type CustomersResponse struct {
+	Type         string     `json:"type"`
+	Data         []Customer `json:"data"`
+	TotalRecords int        `json:"total_records"`
+	CurrentPage  int        `json:"current_page"`
+	TotalPages   int        `json:"total_pages"`
+}
+
+type CustomerPortalUser struct {
+	PortalUserUID string `json:"portal_user_uid"`
+	CustomerUID   string `json:"customer_uid"`
+	FirstName     string `json:"first_name"`
+	LastName      string `json:"last_name"`
+	Email         string `json:"email"`
+	HasAccess     bool   `json:"portal_access"`
+	CreatedAt     string `json:"created_at"`
+	LastLoginAt   string `json:"last_login_at"`
+}
+
+type CustomerPortalUsersResponse struct {
+	Type         string               `json:"type"`
+	Data         []CustomerPortalUser `json:"data"`
+	TotalRecords int                  `json:"total_records"`
+	CurrentPage  int                  `json:"current_page"`
+	TotalPages   int                  `json:"total_pages"`
+}
+
+// UpdatePortalAccess models.
+type UpdatePortalAccessRequest struct {
+	PortalAccess bool `json:"portal_access"`
+}
+
+type UpdatePortalAccessResponse struct {
+	Type    string `json:"type"`
+	Title   string `json:"title"`
+	Message string `json:"message"`
+}
+
+// API Key Models.
+type APIKey struct {
+	APIKeyUID  string     `json:"api_key_uid"`
+	APIKeyName string     `json:"api_key_name"`
+	IsActive   bool       `json:"is_active"`
+	CreatedAt  string     `json:"created_at"`
+	LastUsedAt string     `json:"last_used_at"`
+	ExpiresAt  string     `json:"expires_at"`
+	CreatedBy  *CreatedBy `json:"created_by"`
+}
+
+type APIKeysResponse struct {
+	Type         string   `json:"type"`
+	Data         []APIKey `json:"data"`
+	TotalRecords int      `json:"total_records"`
+	CurrentPage  int      `json:"current_page"`
+	TotalPages   int      `json:"total_pages"`
+}
+
+// DeleteAPIKey models.
+type DeleteAPIKeyResponse struct {
+	Type    string `json:"type"`
+	Title   string `json:"title"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Field names Zuper has used for role and access role attributes, in order of preference. Responses from different
+// endpoints and API versions do not agree on them.
+var (
+	roleUIDFields               = []string{"role_uid", "role_id"}
+	roleNameFields              = []string{"role_name"}
+	roleKeyFields               = []string{"role_key"}
+	accessRoleUIDFields         = []string{"access_role_uid"}
+	accessRoleNameFields        = []string{"role_name", "access_role_name"}
+	accessRoleDescriptionFields = []string{"role_description"}
+)
+
+// UnmarshalJSON decodes a role, accepting the alternative field names and numeric IDs Zuper returns.
+func (r *Role) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*r = Role{
+		RoleUID:  pickString(fields, roleUIDFields...),
+		RoleName: pickString(fields, roleNameFields...),
+		RoleKey:  pickString(fields, roleKeyFields...),
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes an access role, accepting the alternative field names and numeric IDs Zuper returns.
+func (a *AccessRole) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*a = AccessRole{
+		AccessRoleUID:   pickString(fields, accessRoleUIDFields...),
+		AccessRoleName:  pickString(fields, accessRoleNameFields...),
+		RoleDescription: pickString(fields, accessRoleDescriptionFields...),
+	}
+	return nil
+}
+
+// pickString returns the first non-empty value among the named fields, reading numbers as their decimal text.
+func pickString(fields map[string]json.RawMessage, names ...string) string {
+	for _, name := range names {
+		raw, ok := fields[name]
+		if !ok {
+			continue
+		}
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			var n json.Number
+			if err := json.Unmarshal(raw, &n); err != nil {
+				continue
+			}
+			s = n.String()
+		}
+		if s = strings.TrimSpace(s); s != "" {
+			return s
+		}
+	}
+	return ""
+}
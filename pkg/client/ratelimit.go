@@ -0,0 +1,154 @@
+package client
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// RateLimitOptions configures how fast the client sends requests to Zuper.
+type RateLimitOptions struct {
+	// RequestsPerSecond is the most requests sent per second. Zero leaves the rate to the quota Zuper reports.
+	RequestsPerSecond float64
+	// MaxInFlight is the most requests waiting for a response at once. Zero means no limit.
+	MaxInFlight int
+	// QuotaShare is the fraction, between 0 and 1, of the quota reported in Zuper's rate limit headers the client
+	// may use. The rest is left to other integrations sharing the API key. Zero means the whole quota.
+	QuotaShare float64
+}
+
+// rateLimiter paces requests with a token bucket whose rate adapts to the quota Zuper reports, and caps the
+// number of requests in flight. A nil rateLimiter lets every request through.
+type rateLimiter struct {
+	mu          sync.Mutex
+	limit       float64
+	rate        float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+	share       float64
+	inFlight    chan struct{}
+}
+
+// SetRateLimit paces the requests of the client according to opts.
+func (c *Client) SetRateLimit(opts RateLimitOptions) {
+	c.limiter = newRateLimiter(opts)
+}
+
+// newRateLimiter returns a rateLimiter configured with opts.
+func newRateLimiter(opts RateLimitOptions) *rateLimiter {
+	share := opts.QuotaShare
+	if share <= 0 || share > 1 {
+		share = 1
+	}
+	l := &rateLimiter{
+		limit: opts.RequestsPerSecond,
+		rate:  opts.RequestsPerSecond,
+		share: share,
+		last:  time.Now(),
+	}
+	l.tokens = l.burst()
+	if opts.MaxInFlight > 0 {
+		l.inFlight = make(chan struct{}, opts.MaxInFlight)
+	}
+	return l
+}
+
+// burst is the number of requests that may be sent back to back after a quiet period.
+func (l *rateLimiter) burst() float64 {
+	return math.Max(1, l.rate)
+}
+
+// wait blocks until a request may be sent. The returned function must be called once the response is read.
+func (l *rateLimiter) wait(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	release := func() {}
+	if l.inFlight != nil {
+		select {
+		case l.inFlight <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		release = func() { <-l.inFlight }
+	}
+
+	for {
+		delay := l.reserve(time.Now())
+		if delay <= 0 {
+			return release, nil
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			release()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// reserve takes a token if one is available at now, or returns how long until one is.
+func (l *rateLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+	if l.rate <= 0 {
+		return 0
+	}
+	l.tokens = math.Min(l.burst(), l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// observe adapts the rate to the quota described by the rate limit headers of a response, spreading the share
+// of the remaining quota the client may use over the time left until the quota resets.
+func (l *rateLimiter) observe(ctx context.Context, desc *v2.RateLimitDescription, now time.Time) {
+	if l == nil || desc == nil || desc.GetResetAt() == nil {
+		return
+	}
+	overLimit := desc.GetStatus() == v2.RateLimitDescription_STATUS_OVERLIMIT
+	if desc.GetLimit() <= 0 && !overLimit {
+		return
+	}
+	resetAt := desc.GetResetAt().AsTime()
+	window := resetAt.Sub(now)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if window <= 0 {
+		l.rate = l.limit
+		return
+	}
+	// Leave the part of the quota beyond the client's share to the other users of the API key.
+	budget := float64(desc.GetRemaining()) - (1-l.share)*float64(desc.GetLimit())
+	if overLimit || budget < 1 {
+		if resetAt.After(l.pausedUntil) {
+			ctxzap.Extract(ctx).Warn("Zuper quota share used up, pausing requests until it resets",
+				zap.Int64("limit", desc.GetLimit()),
+				zap.Int64("remaining", desc.GetRemaining()),
+				zap.Time("reset_at", resetAt),
+			)
+			l.pausedUntil = resetAt
+		}
+		return
+	}
+	rate := budget / window.Seconds()
+	if l.limit > 0 {
+		rate = math.Min(rate, l.limit)
+	}
+	l.rate = rate
+}
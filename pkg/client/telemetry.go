@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/conductorone/baton-sdk/pkg/metrics"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/status"
+)
+
+// instrumentationName names the tracer and meter of the client.
+const instrumentationName = "baton-zuper"
+
+// tracer creates the spans of Zuper API calls through the tracer provider the SDK installs when OTel is enabled.
+var tracer = otel.Tracer(instrumentationName)
+
+// Span attributes describing a Zuper API call.
+const (
+	attrMethod     = attribute.Key("http.request.method")
+	attrEndpoint   = attribute.Key("url.template")
+	attrStatusCode = attribute.Key("http.response.status_code")
+	attrPage       = attribute.Key("zuper.page")
+	attrRetryCount = attribute.Key("zuper.retry_count")
+)
+
+// telemetry records the latency, errors and rate limit waits of Zuper API calls, and counts the calls to each
+// endpoint for the sync report.
+type telemetry struct {
+	latency       metrics.Int64Histogram
+	errors        metrics.Int64Counter
+	rateLimitWait metrics.Int64Histogram
+
+	mu    sync.Mutex
+	calls map[string]int64
+}
+
+// newTelemetry returns a telemetry reporting to handler.
+func newTelemetry(handler metrics.Handler) *telemetry {
+	return &telemetry{
+		latency: handler.Int64Histogram("zuper_request_duration",
+			"Time taken by Zuper API calls", metrics.Milliseconds),
+		errors: handler.Int64Counter("zuper_request_errors",
+			"Zuper API calls that failed", metrics.Dimensionless),
+		rateLimitWait: handler.Int64Histogram("zuper_rate_limit_wait",
+			"Time Zuper API calls waited for the rate limiter", metrics.Milliseconds),
+		calls: make(map[string]int64),
+	}
+}
+
+// TakeAPICalls returns the number of requests sent to each endpoint, keyed by method and endpoint template, since
+// the last call.
+func (c *Client) TakeAPICalls() map[string]int64 {
+	t := c.telemetry
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	calls := t.calls
+	t.calls = make(map[string]int64)
+	return calls
+}
+
+// assetEndpoint is the endpoint profile picture downloads are reported under, whatever host and path they have.
+const assetEndpoint = "{asset}"
+
+// startSpan starts the span of a call to endpoint with the given query parameters.
+func startSpan(ctx context.Context, method string, endpoint string, query url.Values) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{
+		attrMethod.String(method),
+		attrEndpoint.String(endpoint),
+	}
+	if page, err := strconv.Atoi(query.Get("page")); err == nil {
+		attrs = append(attrs, attrPage.Int(page))
+	}
+	return tracer.Start(ctx, method+" "+endpoint,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// endSpan ends the span of a call retried retries times, recording err if the call failed.
+func endSpan(span trace.Span, retries int, err error) {
+	span.SetAttributes(attrRetryCount.Int(retries))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	span.End()
+}
+
+// recordAttempt records the latency of a single request to endpoint and, if it failed, the error.
+func (t *telemetry) recordAttempt(ctx context.Context, method string, endpoint string, statusCode int, took time.Duration, err error) {
+	tags := map[string]string{
+		"method":   method,
+		"endpoint": endpoint,
+	}
+	if statusCode != 0 {
+		tags["status_code"] = strconv.Itoa(statusCode)
+	}
+	t.latency.Record(ctx, took.Milliseconds(), tags)
+	t.mu.Lock()
+	t.calls[method+" "+endpoint]++
+	t.mu.Unlock()
+	if err != nil {
+		tags["code"] = status.Code(err).String()
+		t.errors.Add(ctx, 1, tags)
+	}
+}
+
+// recordRateLimitWait records how long a request to endpoint waited for the rate limiter.
+func (t *telemetry) recordRateLimitWait(ctx context.Context, endpoint string, waited time.Duration) {
+	t.rateLimitWait.Record(ctx, waited.Milliseconds(), map[string]string{"endpoint": endpoint})
+}
+
+// endpointTemplate replaces the IDs in an API path with placeholders, so that calls to the same endpoint share
+// one span name and one set of metrics.
+func endpointTemplate(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if isPathID(segment) {
+			segments[i] = "{id}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// isPathID reports whether a path segment is a numeric ID or a UUID.
+func isPathID(segment string) bool {
+	if segment == "" {
+		return false
+	}
+	if _, err := strconv.Atoi(segment); err == nil {
+		return true
+	}
+	return len(segment) == 36 && strings.Count(segment, "-") == 4
+}
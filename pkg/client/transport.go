@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// DefaultTimeout is the time allowed for a request to Zuper when no timeout is configured.
+const DefaultTimeout = 300 * time.Second
+
+// maxRedirects is the number of redirects a request follows before giving up, as in the standard library.
+const maxRedirects = 10
+
+// TransportOptions configures how the client connects to Zuper.
+type TransportOptions struct {
+	// ProxyURL is the HTTPS proxy requests go through. When empty, the standard proxy environment variables apply.
+	ProxyURL string
+	// CABundle is a PEM file of certificate authorities trusted in addition to the system ones.
+	CABundle string
+	// ClientCert and ClientKey are the PEM certificate and key presented to Zuper or the proxy for mutual TLS.
+	ClientCert string
+	ClientKey  string
+	// Timeout limits a whole request, including reading the response body, so a body that stalls halfway fails the
+	// request instead of hanging it. Zero uses DefaultTimeout.
+	Timeout time.Duration
+	// ResponseTimeout limits the wait for Zuper to start responding to a request. Zero leaves it to Timeout.
+	ResponseTimeout time.Duration
+}
+
+// NewHTTPClient returns the HTTP client the connector talks to Zuper through, configured with opts. The same client
+// is meant to serve the data center lookup and the Client, so that both honor the proxy, certificates and timeouts.
+// Requests are logged at debug level with the logger of ctx.
+func NewHTTPClient(ctx context.Context, opts TransportOptions) (*uhttp.BaseHttpClient, error) {
+	httpClient, err := newHTTPClient(opts)
+	if err != nil {
+		return nil, err
+	}
+	httpClient.Transport = &loggingTransport{next: httpClient.Transport, logger: ctxzap.Extract(ctx)}
+	return uhttp.NewBaseHttpClientWithContext(ctx, httpClient)
+}
+
+// loggingTransport logs each request and its outcome like the SDK's uhttp transport created with WithLogger. The
+// SDK transport always dials with its own settings and cannot wrap another transport, so it would drop the proxy
+// and response timeout of TransportOptions.
+type loggingTransport struct {
+	next   http.RoundTripper
+	logger *zap.Logger
+}
+
+// loggedResponseHeaders are the rate limit headers logged with each response.
+var loggedResponseHeaders = []string{
+	"X-Ratelimit-Limit",
+	"X-Ratelimit-Remaining",
+	"X-Ratelimit-Reset",
+	"Ratelimit-Limit",
+	"Ratelimit-Remaining",
+	"Ratelimit-Reset",
+	"Retry-After",
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fields := []zap.Field{
+		zap.String("http.method", req.Method),
+		zap.String("http.url_details.host", req.URL.Host),
+		zap.String("http.url_details.path", req.URL.Path),
+	}
+	t.logger.Debug("Request started", fields...)
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	if resp != nil {
+		headers := make(map[string][]string)
+		for _, header := range loggedResponseHeaders {
+			if v := resp.Header.Values(header); len(v) > 0 {
+				headers[header] = v
+			}
+		}
+		fields = append(fields, zap.Int("http.status_code", resp.StatusCode), zap.Any("http.headers", headers))
+	}
+	t.logger.Debug("Request complete", fields...)
+	return resp, err
+}
+
+// newHTTPClient returns an HTTP client configured with opts.
+func newHTTPClient(opts TransportOptions) (*http.Client, error) {
+	tlsConfig, err := opts.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.ResponseHeaderTimeout = opts.ResponseTimeout
+	if opts.ProxyURL != "" {
+		proxyURL, err := url.Parse(opts.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &http.Client{
+		Transport:     transport,
+		Timeout:       timeout,
+		CheckRedirect: sameHostRedirect,
+	}, nil
+}
+
+// sameHostRedirect follows redirects only within the host of the original request. Requests carry the API key in
+// a custom header, which the standard library would forward to any host a response redirects to.
+func sameHostRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	if from := via[0].URL; !strings.EqualFold(req.URL.Host, from.Host) {
+		return fmt.Errorf("refusing redirect from %s to %s", from.Host, req.URL.Host)
+	}
+	return nil
+}
+
+// tlsConfig returns the TLS settings for the CA bundle and client certificate of opts.
+func (opts TransportOptions) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if opts.CABundle != "" {
+		bundle, err := os.ReadFile(opts.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", opts.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if opts.ClientCert != "" || opts.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(opts.ClientCert, opts.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
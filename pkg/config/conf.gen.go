@@ -4,8 +4,35 @@ package config
 import "reflect"
 
 type Zuper struct {
-	ApiUrl string `mapstructure:"api-url"`
-	ApiKey string `mapstructure:"api-key"`
+	ApiUrl                       string   `mapstructure:"api-url"`
+	CompanyName                  string   `mapstructure:"company-name"`
+	ApiKey                       string   `mapstructure:"api-key"`
+	ApiKeyFile                   string   `mapstructure:"api-key-file"`
+	ApiKeyCommand                string   `mapstructure:"api-key-command"`
+	TenantsFile                  string   `mapstructure:"tenants-file"`
+	HttpsProxy                   string   `mapstructure:"https-proxy"`
+	CaBundle                     string   `mapstructure:"ca-bundle"`
+	ClientCert                   string   `mapstructure:"client-cert"`
+	ClientKey                    string   `mapstructure:"client-key"`
+	HttpTimeout                  int      `mapstructure:"http-timeout"`
+	HttpResponseTimeout          int      `mapstructure:"http-response-timeout"`
+	AssetHosts                   []string `mapstructure:"asset-hosts"`
+	RateLimit                    int      `mapstructure:"rate-limit"`
+	MaxInFlight                  int      `mapstructure:"max-in-flight"`
+	QuotaShare                   int      `mapstructure:"quota-share"`
+	CircuitBreakerThreshold      int      `mapstructure:"circuit-breaker-threshold"`
+	CircuitBreakerCoolDown       int      `mapstructure:"circuit-breaker-cool-down"`
+	ContractorUserTypes          []string `mapstructure:"contractor-user-types"`
+	ContractorEmailDomains       []string `mapstructure:"contractor-email-domains"`
+	ContractorDesignationPattern string   `mapstructure:"contractor-designation-pattern"`
+	ContractorEmpCodePrefix      string   `mapstructure:"contractor-emp-code-prefix"`
+	GrantExpiryStore             string   `mapstructure:"grant-expiry-store"`
+	ExpirySweep                  bool     `mapstructure:"expiry-sweep"`
+	DryRun                       bool     `mapstructure:"dry-run"`
+	AuditJournal                 string   `mapstructure:"audit-journal"`
+	SyncReport                   string   `mapstructure:"sync-report"`
+	VerifyWrites                 bool     `mapstructure:"verify-writes"`
+	VerifyTimeout                int      `mapstructure:"verify-timeout"`
 }
 
 func (c *Zuper) findFieldByTag(tagValue string) (any, bool) {
@@ -9,14 +9,152 @@ var (
 		"api-url",
 		field.WithDisplayName("API URL"),
 		field.WithDescription("The URL of the API."),
-		field.WithRequired(true),
+	)
+	companyNameField = field.StringField(
+		"company-name",
+		field.WithDisplayName("Company name"),
+		field.WithDescription("The company login name in Zuper, used to look up the API URL of its data center."),
 	)
 	apiKeyField = field.StringField(
 		"api-key",
 		field.WithDisplayName("API key"),
 		field.WithDescription("API key for authenticating requests to Zuper."),
 		field.WithIsSecret(true),
-		field.WithRequired(true),
+	)
+	apiKeyFileField = field.StringField(
+		"api-key-file",
+		field.WithDisplayName("API key file"),
+		field.WithDescription("Path to a file containing the Zuper API key. The file is read again when Zuper rejects the key. With tenants-file, tenants without a key read the file with their name inserted before the extension."),
+	)
+	apiKeyCommandField = field.StringField(
+		"api-key-command",
+		field.WithDisplayName("API key command"),
+		field.WithDescription("Shell command printing the Zuper API key. The command runs again when Zuper rejects the key. With tenants-file, tenants without a key run it with ZUPER_TENANT set to their name."),
+	)
+	tenantsFileField = field.StringField(
+		"tenants-file",
+		field.WithDisplayName("Tenants file"),
+		field.WithDescription("Path to a JSON file listing the Zuper tenants to sync, each with a name, api_url and api_key, api_key_file or api_key_command. Replaces api-url, company-name and api-key."),
+	)
+	httpsProxyField = field.StringField(
+		"https-proxy",
+		field.WithDisplayName("HTTPS proxy"),
+		field.WithDescription("URL of the proxy requests to Zuper go through. Defaults to the HTTPS_PROXY environment variable."),
+	)
+	caBundleField = field.StringField(
+		"ca-bundle",
+		field.WithDisplayName("CA bundle"),
+		field.WithDescription("Path to a PEM file of certificate authorities to trust in addition to the system ones."),
+	)
+	clientCertField = field.StringField(
+		"client-cert",
+		field.WithDisplayName("Client certificate"),
+		field.WithDescription("Path to a PEM client certificate presented for mutual TLS."),
+	)
+	clientKeyField = field.StringField(
+		"client-key",
+		field.WithDisplayName("Client key"),
+		field.WithDescription("Path to the PEM private key of the client certificate."),
+	)
+	httpTimeoutField = field.IntField(
+		"http-timeout",
+		field.WithDisplayName("HTTP timeout"),
+		field.WithDescription("Seconds allowed for a whole request to Zuper, including reading the response."),
+		field.WithDefaultValue(300),
+	)
+	httpResponseTimeoutField = field.IntField(
+		"http-response-timeout",
+		field.WithDisplayName("HTTP response timeout"),
+		field.WithDescription("Seconds to wait for Zuper to start responding to a request. Zero leaves it to the HTTP timeout."),
+	)
+	assetHostsField = field.StringSliceField(
+		"asset-hosts",
+		field.WithDisplayName("Asset hosts"),
+		field.WithDescription("Hosts besides Zuper's own that profile pictures may be downloaded from, such as a CDN. A host also allows its subdomains."),
+	)
+	rateLimitField = field.IntField(
+		"rate-limit",
+		field.WithDisplayName("Rate limit"),
+		field.WithDescription("Most requests per minute sent to Zuper. Zero leaves the rate to the quota Zuper reports."),
+	)
+	maxInFlightField = field.IntField(
+		"max-in-flight",
+		field.WithDisplayName("Max in-flight requests"),
+		field.WithDescription("Most requests to Zuper waiting for a response at once. Zero means no limit."),
+	)
+	quotaShareField = field.IntField(
+		"quota-share",
+		field.WithDisplayName("Quota share"),
+		field.WithDescription("Percentage of the Zuper API quota the connector may use, leaving the rest to other integrations."),
+		field.WithDefaultValue(100),
+	)
+	circuitBreakerThresholdField = field.IntField(
+		"circuit-breaker-threshold",
+		field.WithDisplayName("Circuit breaker threshold"),
+		field.WithDescription("Consecutive 5xx responses or timeouts from Zuper after which requests fail fast. Zero disables the circuit breaker."),
+		field.WithDefaultValue(5),
+	)
+	circuitBreakerCoolDownField = field.IntField(
+		"circuit-breaker-cool-down",
+		field.WithDisplayName("Circuit breaker cool-down"),
+		field.WithDescription("Seconds requests fail fast once the circuit breaker opens, before a single probe request is sent."),
+		field.WithDefaultValue(30),
+	)
+	contractorUserTypesField = field.StringSliceField(
+		"contractor-user-types",
+		field.WithDisplayName("Contractor user types"),
+		field.WithDescription("Zuper user types that identify contractor or vendor accounts."),
+	)
+	contractorEmailDomainsField = field.StringSliceField(
+		"contractor-email-domains",
+		field.WithDisplayName("Contractor email domains"),
+		field.WithDescription("Email domains that identify contractor or vendor accounts."),
+	)
+	contractorDesignationPatternField = field.StringField(
+		"contractor-designation-pattern",
+		field.WithDisplayName("Contractor designation pattern"),
+		field.WithDescription("Regular expression matched against the user designation to identify contractor or vendor accounts."),
+	)
+	contractorEmpCodePrefixField = field.StringField(
+		"contractor-emp-code-prefix",
+		field.WithDisplayName("Contractor employee code prefix"),
+		field.WithDescription("Employee code prefix that identifies contractor or vendor accounts."),
+	)
+	grantExpiryStoreField = field.StringField(
+		"grant-expiry-store",
+		field.WithDisplayName("Grant expiry store"),
+		field.WithDescription("Path to a JSON file recording time-bound grants. Must be on persistent storage used by a single replica. Required, with expiry-sweep, to grant team or role assignments with an expiry."),
+	)
+	expirySweepField = field.BoolField(
+		"expiry-sweep",
+		field.WithDisplayName("Expiry sweep"),
+		field.WithDescription("Deactivate expired contractor accounts and revoke expired time-bound grants at the start of every sync. Required by grant-expiry-store."),
+	)
+	dryRunField = field.BoolField(
+		"dry-run",
+		field.WithDisplayName("Dry run"),
+		field.WithDescription("Log the requests provisioning would send to Zuper without performing any changes."),
+	)
+	auditJournalField = field.StringField(
+		"audit-journal",
+		field.WithDisplayName("Audit journal"),
+		field.WithDescription("Path to a JSONL file recording every change the connector makes in Zuper."),
+	)
+	syncReportField = field.StringField(
+		"sync-report",
+		field.WithDisplayName("Sync report"),
+		field.WithDescription("Path to a JSON file summarizing the counts, timings and anomalies of the last sync."),
+	)
+	verifyWritesField = field.BoolField(
+		"verify-writes",
+		field.WithDisplayName("Verify writes"),
+		field.WithDescription("Re-read Zuper after role, access role and team grants and revokes until the change shows up."),
+	)
+	verifyTimeoutField = field.IntField(
+		"verify-timeout",
+		field.WithDisplayName("Verify timeout"),
+		field.WithDescription("Seconds to wait for a verified change to show up in Zuper."),
+		field.WithDefaultValue(30),
 	)
 )
 
@@ -24,8 +162,45 @@ var (
 var Config = field.NewConfiguration(
 	[]field.SchemaField{
 		apiUrlField,
+		companyNameField,
 		apiKeyField,
+		apiKeyFileField,
+		apiKeyCommandField,
+		tenantsFileField,
+		httpsProxyField,
+		caBundleField,
+		clientCertField,
+		clientKeyField,
+		httpTimeoutField,
+		httpResponseTimeoutField,
+		assetHostsField,
+		rateLimitField,
+		maxInFlightField,
+		quotaShareField,
+		circuitBreakerThresholdField,
+		circuitBreakerCoolDownField,
+		contractorUserTypesField,
+		contractorEmailDomainsField,
+		contractorDesignationPatternField,
+		contractorEmpCodePrefixField,
+		grantExpiryStoreField,
+		expirySweepField,
+		dryRunField,
+		auditJournalField,
+		syncReportField,
+		verifyWritesField,
+		verifyTimeoutField,
 	},
+	field.WithConstraints(
+		field.FieldsAtLeastOneUsed(apiUrlField, companyNameField, tenantsFileField),
+		field.FieldsAtLeastOneUsed(apiKeyField, apiKeyFileField, apiKeyCommandField, tenantsFileField),
+		field.FieldsMutuallyExclusive(apiKeyField, apiKeyFileField, apiKeyCommandField),
+		field.FieldsMutuallyExclusive(apiKeyField, tenantsFileField),
+		field.FieldsMutuallyExclusive(apiUrlField, tenantsFileField),
+		field.FieldsMutuallyExclusive(companyNameField, tenantsFileField),
+		field.FieldsRequiredTogether(clientCertField, clientKeyField),
+		field.FieldsDependentOn([]field.SchemaField{grantExpiryStoreField}, []field.SchemaField{expirySweepField}),
+	),
 	field.WithConnectorDisplayName("Zuper"),
 	field.WithHelpUrl("/docs/baton/zuper"),
 	field.WithIconUrl("/static/app-icons/zuper.svg"),
@@ -3,6 +3,8 @@ package connector
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -26,32 +28,47 @@ type accessRoleBuilder struct {
 	mu           sync.RWMutex
 	roleCache    map[string]*client.AccessRole
 	lastFetch    time.Time
+	expiries     *grantExpiryStore
+	verifier     *writeVerifier
 }
 
 // newAccessRoleBuilder creates a new accessRoleBuilder instance.
-func newAccessRoleBuilder(client UserClient) *accessRoleBuilder {
+func newAccessRoleBuilder(client UserClient, expiries *grantExpiryStore, verifier *writeVerifier) *accessRoleBuilder {
 	return &accessRoleBuilder{
 		resourceType: accessRoleResourceType,
 		client:       client,
+		expiries:     expiries,
+		verifier:     verifier,
 	}
 }
 
-// UpdateCacheWithUsers updates the access role cache with roles from a list of users.
-func (b *accessRoleBuilder) updateCacheWithUsers(users []*client.ZuperUser) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-	if b.roleCache == nil {
-		b.roleCache = make(map[string]*client.AccessRole)
+// currentAccessRole reads the user back from Zuper and returns the UID of its access role, if any.
+func (b *accessRoleBuilder) currentAccessRole(ctx context.Context, userID string) (string, error) {
+	user, _, err := b.client.GetUserByID(client.WithoutCache(ctx), userID)
+	if err != nil {
+		return "", err
 	}
-	for _, user := range users {
-		if user.AccessRole != nil {
-			b.roleCache[user.AccessRole.AccessRoleUID] = user.AccessRole
-		}
+	if user.AccessRole == nil {
+		return "", nil
 	}
-	b.lastFetch = time.Now()
+	return user.AccessRole.AccessRoleUID, nil
+}
+
+// roles returns a snapshot of the cached access roles, ordered by UID.
+func (b *accessRoleBuilder) roles() []*client.AccessRole {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	roles := make([]*client.AccessRole, 0, len(b.roleCache))
+	for _, role := range b.roleCache {
+		roles = append(roles, role)
+	}
+	sort.Slice(roles, func(i, j int) bool {
+		return roles[i].AccessRoleUID < roles[j].AccessRoleUID
+	})
+	return roles
 }
 
-// loadAccessRoles loads all access roles from all users, handling pagination.
+// loadAccessRoles loads all access roles from all users.
 func (b *accessRoleBuilder) loadAccessRoles(ctx context.Context) error {
 	b.mu.RLock()
 	if time.Since(b.lastFetch) < cacheTTL && len(b.roleCache) > 0 {
@@ -60,27 +77,45 @@ func (b *accessRoleBuilder) loadAccessRoles(ctx context.Context) error {
 	}
 	b.mu.RUnlock()
 
-	var allUsers []*client.ZuperUser
-	pToken := ""
-	for {
-		users, nextToken, _, err := b.client.GetUsers(ctx, client.PageOptions{
-			PageToken: pToken,
-			PageSize:  client.DefaultPageSize,
-		})
+	// Only the access roles are kept: users are dropped as soon as their role is recorded.
+	roleCache := make(map[string]*client.AccessRole)
+	for user, err := range b.client.AllUsers(ctx, nil) {
 		if err != nil {
 			return fmt.Errorf("failed to load users for access role cache: %w", err)
 		}
-		allUsers = append(allUsers, users...)
-		if nextToken == "" {
-			break
+		// An access role without a UID cannot be synced; users holding it are resolved by name.
+		if user.AccessRole != nil && user.AccessRole.AccessRoleUID != "" {
+			roleCache[user.AccessRole.AccessRoleUID] = user.AccessRole
 		}
-		pToken = nextToken
 	}
 
-	b.updateCacheWithUsers(allUsers)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.roleCache = roleCache
+	b.lastFetch = time.Now()
 	return nil
 }
 
+// resolveUID returns the UID of an access role, looking it up by name among the known access roles when Zuper
+// returned the role without one. It reports false if the role cannot be identified.
+func (b *accessRoleBuilder) resolveUID(ctx context.Context, role *client.AccessRole) (string, bool, error) {
+	if role.AccessRoleUID != "" {
+		return role.AccessRoleUID, true, nil
+	}
+	if role.AccessRoleName == "" {
+		return "", false, nil
+	}
+	if err := b.loadAccessRoles(ctx); err != nil {
+		return "", false, err
+	}
+	for _, known := range b.roles() {
+		if strings.EqualFold(known.AccessRoleName, role.AccessRoleName) {
+			return known.AccessRoleUID, true, nil
+		}
+	}
+	return "", false, nil
+}
+
 // ResourceType returns the resource type for access roles.
 func (b *accessRoleBuilder) ResourceType(_ context.Context) *v2.ResourceType {
 	return b.resourceType
@@ -96,7 +131,7 @@ func (b *accessRoleBuilder) List(ctx context.Context, parentResourceID *v2.Resou
 	}
 
 	var resources []*v2.Resource
-	for uid, role := range b.roleCache {
+	for _, role := range b.roles() {
 		profile := map[string]interface{}{
 			"AccessRoleUID":   role.AccessRoleUID,
 			"RoleDescription": role.RoleDescription,
@@ -104,7 +139,7 @@ func (b *accessRoleBuilder) List(ctx context.Context, parentResourceID *v2.Resou
 		accessRoleResource, err := resource.NewRoleResource(
 			role.AccessRoleName,
 			b.resourceType,
-			uid,
+			role.AccessRoleUID,
 			[]resource.RoleTraitOption{resource.WithRoleProfile(profile)},
 		)
 		if err != nil {
@@ -137,11 +172,17 @@ func (b *accessRoleBuilder) Grants(ctx context.Context, resourceUser *v2.Resourc
 }
 
 // Grant assigns an access role to a user if the user does not already have it. Used for access role provisioning.
+// An access role requested with an expiry is revoked by the expiry sweep once it passes.
 func (b *accessRoleBuilder) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) ([]*v2.Grant, annotations.Annotations, error) {
 	userID := principal.Id.Resource
 	accessRoleUID := entitlement.Resource.Id.Resource
 
-	user, _, err := b.client.GetUserByID(ctx, userID)
+	expiresAt, err := parseGrantExpiry(ctx, b.expiries)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, _, err := b.client.GetUserByID(client.WithoutCache(ctx), userID)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get user: %w", err)
 	}
@@ -149,18 +190,28 @@ func (b *accessRoleBuilder) Grant(ctx context.Context, principal *v2.Resource, e
 		return nil, annotations.New(&v2.GrantAlreadyExists{}), nil
 	}
 
-	resp, annos, err := b.client.UpdateUserAccessRole(ctx, userID, accessRoleUID)
+	resp, annos, err := b.client.UpdateUserAccessRoleIfUnchanged(ctx, user, accessRoleUID)
 	if err != nil {
 		return nil, annos, fmt.Errorf("failed to update user access role: %w", err)
 	}
+	err = b.verifier.await(ctx, fmt.Sprintf("user %s does not have access role %s", userID, accessRoleUID), func(ctx context.Context) (bool, error) {
+		current, err := b.currentAccessRole(ctx, userID)
+		return current == accessRoleUID, err
+	})
+	if err != nil {
+		return nil, annos, err
+	}
+	if err := b.expiries.track(entitlement.Resource.Id, assignedEntitlement, userID, expiresAt); err != nil {
+		return nil, annos, fmt.Errorf("failed to record access role expiry: %w", err)
+	}
 
 	grantObj := grant.NewGrant(
 		entitlement.Resource,
 		assignedEntitlement,
 		principal.Id,
-		grant.WithGrantMetadata(map[string]interface{}{
+		grant.WithGrantMetadata(grantMetadataWithExpiry(map[string]interface{}{
 			"message": resp.Message,
-		}),
+		}, expiresAt)),
 	)
 	return []*v2.Grant{grantObj}, annos, nil
 }
@@ -168,18 +219,35 @@ func (b *accessRoleBuilder) Grant(ctx context.Context, principal *v2.Resource, e
 // Revoke removes an access role from a user by setting it to an empty string. Used for access role deprovisioning.
 func (b *accessRoleBuilder) Revoke(ctx context.Context, g *v2.Grant) (annotations.Annotations, error) {
 	userID := g.Principal.Id.Resource
+	accessRoleID := g.GetEntitlement().GetResource().GetId()
 
-	user, _, err := b.client.GetUserByID(ctx, userID)
+	user, _, err := b.client.GetUserByID(client.WithoutCache(ctx), userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 	if user.AccessRole == nil {
 		return annotations.New(&v2.GrantAlreadyRevoked{}), nil
 	}
+	// The user has since moved to another access role; leave it in place.
+	if accessRoleID.GetResource() != "" && user.AccessRole.AccessRoleUID != accessRoleID.GetResource() {
+		return annotations.New(&v2.GrantAlreadyRevoked{}), nil
+	}
 
-	_, annos, err := b.client.UpdateUserAccessRole(ctx, userID, "")
+	_, annos, err := b.client.UpdateUserAccessRoleIfUnchanged(ctx, user, "")
 	if err != nil {
 		return annos, fmt.Errorf("failed to remove user access role: %w", err)
 	}
+	err = b.verifier.await(ctx, fmt.Sprintf("user %s still has an access role", userID), func(ctx context.Context) (bool, error) {
+		current, err := b.currentAccessRole(ctx, userID)
+		return current == "", err
+	})
+	if err != nil {
+		return annos, err
+	}
+	if accessRoleID != nil {
+		if err := b.expiries.forget(accessRoleID, assignedEntitlement, userID); err != nil {
+			return annos, fmt.Errorf("failed to clear access role expiry: %w", err)
+		}
+	}
 	return annos, nil
 }
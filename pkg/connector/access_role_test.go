@@ -7,11 +7,38 @@ import (
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
 	"github.com/conductorone/baton-zuper/pkg/client"
 	"github.com/conductorone/baton-zuper/test"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// TestAccessRoleBuilder_List tests that access roles are listed in a stable order.
+func TestAccessRoleBuilder_List(t *testing.T) {
+	mockCli := &test.MockClient{
+		GetUsersFunc: func(ctx context.Context, options client.PageOptions) ([]*client.ZuperUser, string, annotations.Annotations, error) {
+			var users []*client.ZuperUser
+			for _, uid := range []string{"role-c", "role-a", "role-b", "role-a"} {
+				users = append(users, &client.ZuperUser{UserUID: "user-" + uid, AccessRole: &client.AccessRole{AccessRoleUID: uid, AccessRoleName: uid}})
+			}
+			return users, "", nil, nil
+		},
+	}
+	builder := newAccessRoleBuilder(mockCli, nil, nil)
+
+	for i := 0; i < 3; i++ {
+		resources, _, _, err := builder.List(context.Background(), nil, &pagination.Token{})
+		assert.NoError(t, err)
+		var ids []string
+		for _, r := range resources {
+			ids = append(ids, r.Id.Resource)
+		}
+		assert.Equal(t, []string{"role-a", "role-b", "role-c"}, ids)
+	}
+}
+
 func TestAccessRoleBuilder_Grant(t *testing.T) {
 	mockUser := &client.ZuperUser{
 		UserUID:    "user-1",
@@ -139,3 +166,36 @@ func TestAccessRoleBuilder_Revoke(t *testing.T) {
 		assert.Nil(t, annos)
 	})
 }
+
+// TestAccessRoleBuilder_GrantConflict tests that a concurrent access role change surfaces as a conflict.
+func TestAccessRoleBuilder_GrantConflict(t *testing.T) {
+	observed := &client.ZuperUser{
+		UserUID:    "user-1",
+		UpdatedAt:  "2025-01-02 10:00:00",
+		AccessRole: &client.AccessRole{AccessRoleUID: "manager"},
+	}
+	mockCli := &test.MockClient{
+		GetUserByIDFunc: func(ctx context.Context, userUID string) (*client.ZuperUser, annotations.Annotations, error) {
+			return observed, nil, nil
+		},
+		UpdateAccessRoleIfUnchangedFunc: func(ctx context.Context, user *client.ZuperUser, accessRoleUID string) (*client.UpdateUserRoleResponse, annotations.Annotations, error) {
+			assert.Same(t, observed, user)
+			return nil, nil, &client.ConflictError{
+				UserUID:           user.UserUID,
+				Field:             "access_role",
+				Expected:          "manager",
+				Actual:            "admin",
+				ExpectedUpdatedAt: user.UpdatedAt,
+				ActualUpdatedAt:   "2025-01-02 10:05:00",
+			}
+		},
+	}
+	builder := newAccessRoleBuilder(mockCli, nil, nil)
+	ent := &v2.Entitlement{Resource: &v2.Resource{Id: &v2.ResourceId{ResourceType: accessRoleResourceType.Id, Resource: "technician"}}}
+	userRes := &v2.Resource{Id: &v2.ResourceId{ResourceType: userResourceType.Id, Resource: "user-1"}}
+
+	_, _, err := builder.Grant(context.Background(), userRes, ent)
+	var conflict *client.ConflictError
+	assert.ErrorAs(t, err, &conflict)
+	assert.Equal(t, codes.Aborted, status.Code(err))
+}
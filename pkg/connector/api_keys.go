@@ -0,0 +1,129 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-zuper/pkg/client"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// apiKeyClient defines the client methods required to sync and revoke API keys.
+type apiKeyClient interface {
+	GetAPIKeys(ctx context.Context, options client.PageOptions) ([]*client.APIKey, string, annotations.Annotations, error)
+	DeleteAPIKey(ctx context.Context, apiKeyUID string) (*client.DeleteAPIKeyResponse, annotations.Annotations, error)
+}
+
+// apiKeyBuilder manages API key resources.
+type apiKeyBuilder struct {
+	resourceType *v2.ResourceType
+	client       apiKeyClient
+}
+
+// ResourceType returns the resource type for API keys.
+func (a *apiKeyBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return a.resourceType
+}
+
+// parseIntoAPIKeyResource converts an APIKey into a Baton secret resource.
+func parseIntoAPIKeyResource(key *client.APIKey) (*v2.Resource, error) {
+	var traitOpts []resource.SecretTraitOption
+	if createdAt, ok := parseZuperTime(key.CreatedAt); ok {
+		traitOpts = append(traitOpts, resource.WithSecretCreatedAt(createdAt))
+	}
+	if lastUsed, ok := parseZuperTime(key.LastUsedAt); ok {
+		traitOpts = append(traitOpts, resource.WithSecretLastUsedAt(lastUsed))
+	}
+	if expiresAt, ok := parseZuperTime(key.ExpiresAt); ok {
+		traitOpts = append(traitOpts, resource.WithSecretExpiresAt(expiresAt))
+	}
+	if key.CreatedBy != nil && key.CreatedBy.UserUID != "" {
+		ownerID := makeUserSubjectID(key.CreatedBy.UserUID)
+		traitOpts = append(traitOpts,
+			resource.WithSecretCreatedByID(ownerID),
+			resource.WithSecretIdentityID(ownerID),
+		)
+	}
+
+	displayName := key.APIKeyName
+	if displayName == "" {
+		displayName = key.APIKeyUID
+	}
+
+	return resource.NewSecretResource(
+		displayName,
+		apiKeyResourceType,
+		key.APIKeyUID,
+		traitOpts,
+	)
+}
+
+// List returns the API keys as Baton resources, with pagination.
+func (a *apiKeyBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	var resources []*v2.Resource
+	bag, pageToken, err := parsePageToken(pToken.Token, &v2.ResourceId{ResourceType: apiKeyResourceType.Id})
+	if err != nil {
+		return nil, "", nil, err
+	}
+	keys, nextPageToken, annos, err := a.client.GetAPIKeys(ctx, client.PageOptions{
+		PageSize:  pToken.Size,
+		PageToken: pageToken,
+	})
+	if err != nil {
+		return nil, "", nil, err
+	}
+	for _, key := range keys {
+		keyResource, err := parseIntoAPIKeyResource(key)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		resources = append(resources, keyResource)
+	}
+	var outToken string
+	if nextPageToken != "" {
+		outToken, err = bag.NextToken(nextPageToken)
+		if err != nil {
+			return nil, "", nil, err
+		}
+	}
+	return resources, outToken, annos, nil
+}
+
+// Entitlements returns no entitlements for API keys.
+func (a *apiKeyBuilder) Entitlements(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+// Grants returns no grants for API keys.
+func (a *apiKeyBuilder) Grants(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+// Delete revokes an API key in Zuper. Keys that no longer exist are treated as already revoked.
+func (a *apiKeyBuilder) Delete(ctx context.Context, resourceId *v2.ResourceId) (annotations.Annotations, error) {
+	if resourceId.ResourceType != apiKeyResourceType.Id {
+		return nil, fmt.Errorf("unexpected resource type for API key deletion: %s", resourceId.ResourceType)
+	}
+
+	_, annos, err := a.client.DeleteAPIKey(ctx, resourceId.Resource)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return annos, nil
+		}
+		return annos, fmt.Errorf("failed to delete API key %s: %w", resourceId.Resource, err)
+	}
+	return annos, nil
+}
+
+// newAPIKeyBuilder creates a new instance of apiKeyBuilder.
+func newAPIKeyBuilder(client apiKeyClient) *apiKeyBuilder {
+	return &apiKeyBuilder{
+		resourceType: apiKeyResourceType,
+		client:       client,
+	}
+}
@@ -0,0 +1,83 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-zuper/pkg/client"
+	"github.com/conductorone/baton-zuper/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAPIKeyBuilder_List(t *testing.T) {
+	var mockKeys []*client.APIKey
+	require.NoError(t, json.Unmarshal([]byte(test.ReadFile("api_keys_success.json")), &mockKeys))
+
+	mockCli := &test.MockClient{
+		GetAPIKeysFunc: func(ctx context.Context, options client.PageOptions) ([]*client.APIKey, string, annotations.Annotations, error) {
+			return mockKeys, "", nil, nil
+		},
+	}
+	builder := newAPIKeyBuilder(mockCli)
+
+	resources, nextToken, _, err := builder.List(context.Background(), nil, &pagination.Token{})
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	assert.Empty(t, nextToken)
+	assert.Equal(t, "ConductorOne", resources[0].DisplayName)
+
+	var secretTrait v2.SecretTrait
+	annos := annotations.Annotations(resources[0].Annotations)
+	ok, err := annos.Pick(&secretTrait)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "c3dea3e3-8bc3-459f-aaeb-04fd6f501fa5", secretTrait.GetCreatedById().GetResource())
+	assert.NotNil(t, secretTrait.GetCreatedAt())
+	assert.NotNil(t, secretTrait.GetLastUsedAt())
+	assert.Nil(t, secretTrait.GetExpiresAt())
+}
+
+func TestAPIKeyBuilder_Delete(t *testing.T) {
+	keyID := &v2.ResourceId{ResourceType: apiKeyResourceType.Id, Resource: "key-1"}
+
+	t.Run("revokes key", func(t *testing.T) {
+		var deleted string
+		builder := newAPIKeyBuilder(&test.MockClient{
+			DeleteAPIKeyFunc: func(ctx context.Context, apiKeyUID string) (*client.DeleteAPIKeyResponse, annotations.Annotations, error) {
+				deleted = apiKeyUID
+				return &client.DeleteAPIKeyResponse{Message: "deleted"}, nil, nil
+			},
+		})
+		_, err := builder.Delete(context.Background(), keyID)
+		assert.NoError(t, err)
+		assert.Equal(t, "key-1", deleted)
+	})
+
+	t.Run("missing key is treated as revoked", func(t *testing.T) {
+		builder := newAPIKeyBuilder(&test.MockClient{
+			DeleteAPIKeyFunc: func(ctx context.Context, apiKeyUID string) (*client.DeleteAPIKeyResponse, annotations.Annotations, error) {
+				return nil, nil, status.Error(codes.NotFound, "not found")
+			},
+		})
+		_, err := builder.Delete(context.Background(), keyID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("client error", func(t *testing.T) {
+		builder := newAPIKeyBuilder(&test.MockClient{
+			DeleteAPIKeyFunc: func(ctx context.Context, apiKeyUID string) (*client.DeleteAPIKeyResponse, annotations.Annotations, error) {
+				return nil, nil, errors.New("boom")
+			},
+		})
+		_, err := builder.Delete(context.Background(), keyID)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,105 @@
+package connector
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/conductorone/baton-zuper/pkg/client"
+)
+
+// accountClass identifies whether a Zuper account belongs to an employee or a contractor.
+type accountClass string
+
+const (
+	accountClassEmployee   accountClass = "employee"
+	accountClassContractor accountClass = "contractor"
+)
+
+// ClassificationRules configures how Zuper users are classified as contractor or vendor accounts.
+// A user matching any rule is classified as a contractor; everyone else is an employee.
+type ClassificationRules struct {
+	UserTypes          []string
+	EmailDomains       []string
+	DesignationPattern string
+	EmpCodePrefix      string
+}
+
+// accountClassifier applies ClassificationRules to Zuper users.
+type accountClassifier struct {
+	userTypes     map[string]struct{}
+	emailDomains  map[string]struct{}
+	designation   *regexp.Regexp
+	empCodePrefix string
+}
+
+// newAccountClassifier compiles the classification rules.
+func newAccountClassifier(rules ClassificationRules) (*accountClassifier, error) {
+	c := &accountClassifier{
+		userTypes:     make(map[string]struct{}),
+		emailDomains:  make(map[string]struct{}),
+		empCodePrefix: rules.EmpCodePrefix,
+	}
+	for _, userType := range rules.UserTypes {
+		if userType = strings.ToLower(strings.TrimSpace(userType)); userType != "" {
+			c.userTypes[userType] = struct{}{}
+		}
+	}
+	for _, domain := range rules.EmailDomains {
+		if domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@")); domain != "" {
+			c.emailDomains[domain] = struct{}{}
+		}
+	}
+	if rules.DesignationPattern != "" {
+		re, err := regexp.Compile(rules.DesignationPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid contractor designation pattern: %w", err)
+		}
+		c.designation = re
+	}
+	return c, nil
+}
+
+// classify returns the account class of a user. A nil classifier treats every user as an employee.
+func (c *accountClassifier) classify(user *client.ZuperUser) accountClass {
+	if c == nil {
+		return accountClassEmployee
+	}
+	if _, ok := c.userTypes[strings.ToLower(user.UserType)]; ok && user.UserType != "" {
+		return accountClassContractor
+	}
+	if at := strings.LastIndex(user.Email, "@"); at >= 0 {
+		if _, ok := c.emailDomains[strings.ToLower(user.Email[at+1:])]; ok {
+			return accountClassContractor
+		}
+	}
+	if c.designation != nil && user.Designation != "" && c.designation.MatchString(user.Designation) {
+		return accountClassContractor
+	}
+	if c.empCodePrefix != "" && strings.HasPrefix(user.EmpCode, c.empCodePrefix) {
+		return accountClassContractor
+	}
+	return accountClassEmployee
+}
+
+// isExpired reports whether an active account has passed its scheduled deactivation time.
+func isExpired(user *client.ZuperUser, now time.Time) bool {
+	if !user.IsActive || user.IsDeleted {
+		return false
+	}
+	expiresAt, ok := parseZuperTime(user.AccountExpiresAt)
+	return ok && !now.Before(expiresAt)
+}
+
+// parseExpiryDate parses an account or grant expiry given as a date or RFC 3339 timestamp.
+func parseExpiryDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid expiry %q: expected YYYY-MM-DD or RFC 3339 timestamp", value)
+	}
+	return t.UTC(), nil
+}
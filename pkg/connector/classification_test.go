@@ -0,0 +1,57 @@
+package connector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/conductorone/baton-zuper/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAccountClassifier validates each contractor classification rule.
+func TestAccountClassifier(t *testing.T) {
+	classifier, err := newAccountClassifier(ClassificationRules{
+		UserTypes:          []string{"Subcontractor"},
+		EmailDomains:       []string{"@vendor.example"},
+		DesignationPattern: `(?i)contract`,
+		EmpCodePrefix:      "C-",
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		user *client.ZuperUser
+		want accountClass
+	}{
+		{"employee", &client.ZuperUser{Email: "ana@powin.example", Designation: "Technician", EmpCode: "0098"}, accountClassEmployee},
+		{"user type", &client.ZuperUser{UserType: "subcontractor"}, accountClassContractor},
+		{"email domain", &client.ZuperUser{Email: "bob@Vendor.Example"}, accountClassContractor},
+		{"designation", &client.ZuperUser{Designation: "Contract Electrician"}, accountClassContractor},
+		{"emp code prefix", &client.ZuperUser{EmpCode: "C-001"}, accountClassContractor},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, classifier.classify(tt.user))
+		})
+	}
+
+	t.Run("nil classifier", func(t *testing.T) {
+		var nilClassifier *accountClassifier
+		assert.Equal(t, accountClassEmployee, nilClassifier.classify(&client.ZuperUser{UserType: "subcontractor"}))
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		_, err := newAccountClassifier(ClassificationRules{DesignationPattern: "("})
+		assert.Error(t, err)
+	})
+}
+
+// TestIsExpired validates scheduled deactivation checks.
+func TestIsExpired(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	assert.True(t, isExpired(&client.ZuperUser{IsActive: true, AccountExpiresAt: "2025-05-31T00:00:00Z"}, now))
+	assert.False(t, isExpired(&client.ZuperUser{IsActive: true, AccountExpiresAt: "2025-06-02T00:00:00Z"}, now))
+	assert.False(t, isExpired(&client.ZuperUser{IsActive: false, AccountExpiresAt: "2025-05-31T00:00:00Z"}, now))
+	assert.False(t, isExpired(&client.ZuperUser{IsActive: true}, now))
+}
@@ -2,36 +2,182 @@ package connector
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
-	"net/http"
+	"time"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
 	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
-	"github.com/conductorone/baton-sdk/pkg/uhttp"
 	"github.com/conductorone/baton-zuper/pkg/client"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"go.uber.org/zap"
 )
 
 type Connector struct {
-	client *client.Client
+	// tenant names the Zuper account when the connector is one of several run by a MultiTenantConnector.
+	tenant     string
+	client     *client.Client
+	classifier *accountClassifier
+	expiries   *grantExpiryStore
+	sweep      bool
+	dryRun     bool
+	verifier   *writeVerifier
+
+	// Client settings recorded by options and applied when the client is created.
+	companyName string
+	transport   client.TransportOptions
+	journalPath string
+	keySource   client.APIKeySource
+	rateLimit   client.RateLimitOptions
+	breaker     client.CircuitBreakerOptions
+	assetHosts  []string
+}
+
+// Option configures optional connector behavior.
+type Option func(*Connector) error
+
+// WithClassificationRules classifies users as employees or contractors using the given rules.
+func WithClassificationRules(rules ClassificationRules) Option {
+	return func(c *Connector) error {
+		classifier, err := newAccountClassifier(rules)
+		if err != nil {
+			return err
+		}
+		c.classifier = classifier
+		return nil
+	}
+}
+
+// WithGrantExpiryStore enables time-bound grants, recording their expiry in a JSON file at path. It requires
+// WithExpirySweep, which revokes the grants once they expire.
+func WithGrantExpiryStore(path string) Option {
+	return func(c *Connector) error {
+		if path == "" {
+			return nil
+		}
+		store, err := newGrantExpiryStore(tenantPath(path, c.tenant))
+		if err != nil {
+			return err
+		}
+		c.expiries = store
+		return nil
+	}
+}
+
+// WithExpirySweep deactivates expired accounts and revokes expired time-bound grants at the start of every sync.
+// Without it the connector accepts no expiry, as nothing would act on it.
+func WithExpirySweep(enabled bool) Option {
+	return func(c *Connector) error {
+		c.sweep = enabled
+		return nil
+	}
+}
+
+// WithDryRun makes provisioning log and annotate the requests it would send to Zuper instead of sending them.
+func WithDryRun(enabled bool) Option {
+	return func(c *Connector) error {
+		c.dryRun = enabled
+		return nil
+	}
+}
+
+// WithAuditJournal appends a JSONL entry to the file at path for every change the connector makes in Zuper.
+func WithAuditJournal(path string) Option {
+	return func(c *Connector) error {
+		c.journalPath = path
+		return nil
+	}
+}
+
+// WithAPIKeySource reloads the API key from source whenever Zuper rejects it, so that a rotated key is picked up.
+func WithAPIKeySource(source client.APIKeySource) Option {
+	return func(c *Connector) error {
+		c.keySource = source
+		return nil
+	}
+}
+
+// WithCompanyName looks up the API URL of the data center hosting the company with the given login name.
+// If an API URL is also given, it must point at that data center.
+func WithCompanyName(name string) Option {
+	return func(c *Connector) error {
+		c.companyName = name
+		return nil
+	}
+}
+
+// WithHTTPTransport configures the proxy, TLS settings and timeouts of the HTTP client used to reach Zuper.
+func WithHTTPTransport(transport client.TransportOptions) Option {
+	return func(c *Connector) error {
+		c.transport = transport
+		return nil
+	}
+}
+
+// WithRateLimit paces requests to Zuper and caps how many are in flight, adapting to the quota Zuper reports.
+func WithRateLimit(rateLimit client.RateLimitOptions) Option {
+	return func(c *Connector) error {
+		c.rateLimit = rateLimit
+		return nil
+	}
+}
+
+// WithCircuitBreaker fails requests fast while Zuper keeps answering with 5xx responses or timing out.
+func WithCircuitBreaker(breaker client.CircuitBreakerOptions) Option {
+	return func(c *Connector) error {
+		c.breaker = breaker
+		return nil
+	}
+}
+
+// WithAssetHosts lets profile pictures be downloaded from the given hosts, besides the Zuper API host and Zuper's
+// own domains.
+func WithAssetHosts(hosts []string) Option {
+	return func(c *Connector) error {
+		c.assetHosts = hosts
+		return nil
+	}
+}
+
+// WithWriteVerification re-reads Zuper after every role, access role and team grant or revoke, waiting up to
+// timeout for the change to show up. A timeout of zero disables verification.
+func WithWriteVerification(timeout time.Duration) Option {
+	return func(c *Connector) error {
+		c.verifier = newWriteVerifier(timeout)
+		return nil
+	}
 }
 
 // ResourceSyncers returns a ResourceSyncer for each resource type that should be synced from the upstream service.
 func (d *Connector) ResourceSyncers(ctx context.Context) []connectorbuilder.ResourceSyncer {
+	accessRoles := newAccessRoleBuilder(d.client, d.expiries, d.verifier)
 	return []connectorbuilder.ResourceSyncer{
-		newUserBuilder(d.client),
-		newRoleBuilder(d.client),
-		newAccessRoleBuilder(d.client),
-		newTeamBuilder(d.client),
+		newUserBuilder(d.client, d.classifier, accessRoles, d.sweep),
+		newRoleBuilder(d.client, d.expiries, d.verifier),
+		accessRoles,
+		newPermissionBuilder(d.client, accessRoles),
+		newTeamBuilder(d.client, d.expiries, d.verifier),
+		newTerritoryBuilder(d.client),
+		newSkillBuilder(d.client),
+		newCustomerBuilder(d.client),
+		newCustomerPortalUserBuilder(d.client),
+		newAPIKeyBuilder(d.client),
 	}
 }
 
 // Asset takes an input AssetRef and attempts to fetch it using the connector's authenticated http client
 // It streams a response, always starting with a metadata object, following by chunked payloads for the asset.
 func (d *Connector) Asset(ctx context.Context, asset *v2.AssetRef) (string, io.ReadCloser, error) {
-	return "", nil, nil
+	if asset.GetId() == "" {
+		return "", nil, fmt.Errorf("asset id is required")
+	}
+	contentType, data, err := d.client.GetProfilePicture(ctx, asset.GetId())
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to fetch asset: %w", err)
+	}
+	return contentType, data, nil
 }
 
 // Metadata returns metadata about the connector.
@@ -81,27 +227,112 @@ func (d *Connector) Metadata(ctx context.Context) (*v2.ConnectorMetadata, error)
 					Placeholder: "EMP12345",
 					Order:       4,
 				},
+				"account_class": {
+					DisplayName: "Account Class",
+					Required:    false,
+					Description: "Either employee or contractor. Defaults to employee.",
+					Field: &v2.ConnectorAccountCreationSchema_Field_StringField{
+						StringField: &v2.ConnectorAccountCreationSchema_StringField{},
+					},
+					Placeholder: "contractor",
+					Order:       5,
+				},
+				"expires_at": {
+					DisplayName: "Expires At",
+					Required:    false,
+					Description: "Date (YYYY-MM-DD) after which a contractor account is deactivated.",
+					Field: &v2.ConnectorAccountCreationSchema_Field_StringField{
+						StringField: &v2.ConnectorAccountCreationSchema_StringField{},
+					},
+					Placeholder: "2025-12-31",
+					Order:       6,
+				},
+				"access_role": {
+					DisplayName: "Access Role",
+					Required:    false,
+					Description: "ID of the access role assigned to the new user.",
+					Field: &v2.ConnectorAccountCreationSchema_Field_StringField{
+						StringField: &v2.ConnectorAccountCreationSchema_StringField{},
+					},
+					Order: 7,
+				},
+				"teams": {
+					DisplayName: "Teams",
+					Required:    false,
+					Description: "IDs of the teams the new user is assigned to.",
+					Field: &v2.ConnectorAccountCreationSchema_Field_StringListField{
+						StringListField: &v2.ConnectorAccountCreationSchema_StringListField{},
+					},
+					Order: 8,
+				},
 			},
 		},
 	}, nil
 }
 
 // Validate is called to ensure that the connector is properly configured. It should exercise any API credentials
-// to be sure that they are valid.
+// to be sure that they are valid. The SDK calls it at the start of every sync, so it also runs the expiry sweep.
 func (d *Connector) Validate(ctx context.Context) (annotations.Annotations, error) {
+	if d.sweep {
+		d.runExpirySweep(ctx, time.Now())
+	}
 	return nil, nil
 }
 
 // New returns a new instance of the connector.
-func New(ctx context.Context, apiUrl string, token string) (*Connector, error) {
+func New(ctx context.Context, apiUrl string, token string, opts ...Option) (*Connector, error) {
+	return newConnector(ctx, "", apiUrl, token, opts...)
+}
+
+// newConnector returns a connector for the named tenant, or for the only tenant when tenant is empty.
+func newConnector(ctx context.Context, tenant string, apiUrl string, token string, opts ...Option) (*Connector, error) {
 	l := ctxzap.Extract(ctx)
-	httpClient := uhttp.NewBaseHttpClient(&http.Client{})
-	zuperClient, err := client.New(ctx, client.NewClient(ctx, apiUrl, token, httpClient))
+	c := &Connector{
+		tenant: tenant,
+	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			l.Error("error configuring connector", zap.Error(err))
+			return nil, err
+		}
+	}
+	if c.expiries != nil && !c.sweep {
+		err := errors.New("the grant expiry store requires the expiry sweep, which revokes the grants once they expire")
+		l.Error("error configuring connector", zap.Error(err))
+		return nil, err
+	}
+
+	httpClient, err := client.NewHTTPClient(ctx, c.transport)
 	if err != nil {
 		l.Error("error creating Zuper client", zap.Error(err))
 		return nil, err
 	}
-	return &Connector{
-		client: zuperClient,
-	}, nil
+	apiUrl, err = client.ResolveAPIURL(ctx, apiUrl, c.companyName, httpClient)
+	if err != nil {
+		l.Error("error resolving Zuper API URL", zap.Error(err))
+		return nil, err
+	}
+	c.client = client.NewClient(ctx, apiUrl, token, httpClient)
+	c.client.SetDryRun(c.dryRun)
+	c.client.SetRateLimit(c.rateLimit)
+	c.client.SetCircuitBreaker(c.breaker)
+	c.client.SetAssetHosts(c.assetHosts)
+	if c.keySource != nil {
+		c.client.SetAPIKeySource(c.keySource)
+	}
+	if c.journalPath != "" {
+		if err := c.client.SetJournal(tenantPath(c.journalPath, c.tenant)); err != nil {
+			l.Error("error opening audit journal", zap.Error(err))
+			return nil, err
+		}
+	}
+
+	if c.dryRun && c.expiries != nil {
+		c.expiries.dryRun = true
+	}
+	// Nothing changes in a dry run, so there is nothing to verify.
+	if c.dryRun {
+		c.verifier = nil
+	}
+	return c, nil
 }
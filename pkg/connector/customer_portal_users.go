@@ -0,0 +1,130 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-zuper/pkg/client"
+)
+
+// customerPortalUserBuilder manages portal user resources, listed under their parent customer.
+type customerPortalUserBuilder struct {
+	resourceType *v2.ResourceType
+	client       customerClient
+}
+
+// ResourceType returns the resource type for customer portal users.
+func (p *customerPortalUserBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return p.resourceType
+}
+
+// parseIntoCustomerPortalUserResource converts a CustomerPortalUser into a Baton v2.Resource.
+func parseIntoCustomerPortalUserResource(user *client.CustomerPortalUser, parentResourceID *v2.ResourceId) (*v2.Resource, error) {
+	userStatus := v2.UserTrait_Status_STATUS_ENABLED
+	if !user.HasAccess {
+		userStatus = v2.UserTrait_Status_STATUS_DISABLED
+	}
+
+	profile := map[string]interface{}{
+		"FirstName":    user.FirstName,
+		"LastName":     user.LastName,
+		"Email":        user.Email,
+		"CustomerUID":  user.CustomerUID,
+		"PortalAccess": user.HasAccess,
+		"CreatedAt":    user.CreatedAt,
+		"LastLoginAt":  user.LastLoginAt,
+	}
+
+	userTraits := []resource.UserTraitOption{
+		resource.WithUserProfile(profile),
+		resource.WithStatus(userStatus),
+		resource.WithUserLogin(user.Email),
+		resource.WithEmail(user.Email, true),
+		resource.WithAccountType(v2.UserTrait_ACCOUNT_TYPE_HUMAN),
+	}
+	if createdAt, ok := parseZuperTime(user.CreatedAt); ok {
+		userTraits = append(userTraits, resource.WithCreatedAt(createdAt))
+	}
+	if lastLogin, ok := parseZuperTime(user.LastLoginAt); ok {
+		userTraits = append(userTraits, resource.WithLastLogin(lastLogin))
+	}
+
+	displayName := strings.TrimSpace(fmt.Sprintf("%s %s", user.FirstName, user.LastName))
+	if displayName == "" {
+		displayName = user.Email
+	}
+
+	return resource.NewUserResource(
+		displayName,
+		customerPortalUserResourceType,
+		user.PortalUserUID,
+		userTraits,
+		resource.WithParentResourceID(parentResourceID),
+	)
+}
+
+// List returns the portal users of a customer as Baton resources, with pagination.
+func (p *customerPortalUserBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentResourceID == nil || parentResourceID.ResourceType != customerResourceType.Id {
+		return nil, "", nil, nil
+	}
+
+	var resources []*v2.Resource
+	bag, pageToken, err := parsePageToken(pToken.Token, &v2.ResourceId{ResourceType: customerPortalUserResourceType.Id})
+	if err != nil {
+		return nil, "", nil, err
+	}
+	users, nextPageToken, annos, err := p.client.GetCustomerPortalUsers(ctx, parentResourceID.Resource, client.PageOptions{
+		PageSize:  pToken.Size,
+		PageToken: pageToken,
+	})
+	if err != nil {
+		return nil, "", nil, err
+	}
+	for _, user := range users {
+		userResource, err := parseIntoCustomerPortalUserResource(user, parentResourceID)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		resources = append(resources, userResource)
+	}
+	var outToken string
+	if nextPageToken != "" {
+		outToken, err = bag.NextToken(nextPageToken)
+		if err != nil {
+			return nil, "", nil, err
+		}
+	}
+	return resources, outToken, annos, nil
+}
+
+// Entitlements returns no entitlements for customer portal users.
+func (p *customerPortalUserBuilder) Entitlements(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+// Grants returns no grants for customer portal users. Portal access is granted by the customerBuilder.
+func (p *customerPortalUserBuilder) Grants(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+// makeCustomerPortalUserSubjectID creates a ResourceId for a customer portal user.
+func makeCustomerPortalUserSubjectID(portalUserID string) *v2.ResourceId {
+	return &v2.ResourceId{
+		ResourceType: customerPortalUserResourceType.Id,
+		Resource:     portalUserID,
+	}
+}
+
+// newCustomerPortalUserBuilder creates a new instance of customerPortalUserBuilder.
+func newCustomerPortalUserBuilder(client customerClient) *customerPortalUserBuilder {
+	return &customerPortalUserBuilder{
+		resourceType: customerPortalUserResourceType,
+		client:       client,
+	}
+}
@@ -0,0 +1,181 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-zuper/pkg/client"
+)
+
+// Entitlement value representing access to a customer's portal.
+const (
+	entitlementPortalAccess = "portal_access"
+)
+
+// customerClient defines the client methods required to sync customers and their portal users.
+type customerClient interface {
+	GetCustomers(ctx context.Context, options client.PageOptions) ([]*client.Customer, string, annotations.Annotations, error)
+	GetCustomerPortalUsers(ctx context.Context, customerUID string, options client.PageOptions) ([]*client.CustomerPortalUser, string, annotations.Annotations, error)
+	HasPortalAccess(ctx context.Context, customerUID string, portalUserUID string) (bool, error)
+	UpdateCustomerPortalAccess(ctx context.Context, customerUID string, portalUserUID string, enabled bool) (*client.UpdatePortalAccessResponse, annotations.Annotations, error)
+}
+
+// customerBuilder manages customer resources and their portal access entitlement.
+type customerBuilder struct {
+	resourceType *v2.ResourceType
+	client       customerClient
+	members      *membership
+}
+
+// ResourceType returns the resource type for customers.
+func (c *customerBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return c.resourceType
+}
+
+// customerDisplayName returns the company name of a customer, falling back to the contact name.
+func customerDisplayName(customer *client.Customer) string {
+	if customer.CustomerCompanyName != "" {
+		return customer.CustomerCompanyName
+	}
+	name := strings.TrimSpace(fmt.Sprintf("%s %s", customer.CustomerFirstName, customer.CustomerLastName))
+	if name == "" {
+		return customer.CustomerUID
+	}
+	return name
+}
+
+// parseIntoCustomerResource converts a Customer into a Baton v2.Resource.
+func parseIntoCustomerResource(customer *client.Customer) (*v2.Resource, error) {
+	profile := map[string]interface{}{
+		"customer_first_name":   customer.CustomerFirstName,
+		"customer_last_name":    customer.CustomerLastName,
+		"customer_email":        customer.CustomerEmail,
+		"customer_company_name": customer.CustomerCompanyName,
+		"is_active":             customer.IsActive,
+		"created_at":            customer.CreatedAt,
+		"updated_at":            customer.UpdatedAt,
+	}
+	return resource.NewGroupResource(
+		customerDisplayName(customer),
+		customerResourceType,
+		customer.CustomerUID,
+		[]resource.GroupTraitOption{
+			resource.WithGroupProfile(profile),
+		},
+		resource.WithAnnotation(&v2.ChildResourceType{ResourceTypeId: customerPortalUserResourceType.Id}),
+	)
+}
+
+// List returns the customers as Baton resources, with pagination.
+func (c *customerBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	return listResources(ctx, customerResourceType, pToken, c.client.GetCustomers, parseIntoCustomerResource)
+}
+
+// Entitlements returns a "portal_access" entitlement for each customer, grantable to its portal users.
+func (c *customerBuilder) Entitlements(ctx context.Context, customerResource *v2.Resource, pToken *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return c.members.entitlements(
+		customerResource,
+		fmt.Sprintf("%s portal access", customerResource.DisplayName),
+		fmt.Sprintf("Access to the customer portal of %s", customerResource.DisplayName),
+	)
+}
+
+// Grants returns a "portal_access" grant for each portal user of the customer with access enabled.
+func (c *customerBuilder) Grants(ctx context.Context, customerResource *v2.Resource, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	customerID := customerResource.Id.Resource
+	bag, pageToken, err := parsePageToken(pToken.Token, customerResource.Id)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	users, nextPageToken, annos, err := c.client.GetCustomerPortalUsers(ctx, customerID, client.PageOptions{
+		PageSize:  pToken.Size,
+		PageToken: pageToken,
+	})
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to get portal users for customer %s: %w", customerID, err)
+	}
+
+	var grants []*v2.Grant
+	for _, user := range users {
+		if !user.HasAccess {
+			continue
+		}
+		grantObj := grant.NewGrant(
+			customerResource,
+			entitlementPortalAccess,
+			makeCustomerPortalUserSubjectID(user.PortalUserUID),
+			grant.WithGrantMetadata(map[string]interface{}{
+				"customer_id": customerID,
+				"user_id":     user.PortalUserUID,
+				"username":    user.Email,
+			}),
+		)
+		grants = append(grants, grantObj)
+	}
+
+	var outToken string
+	if nextPageToken != "" {
+		outToken, err = bag.NextToken(nextPageToken)
+		if err != nil {
+			return nil, "", nil, err
+		}
+	}
+	return grants, outToken, annos, nil
+}
+
+// hasPortalAccess checks if a portal user has access to a customer's portal, reading past the HTTP cache so Grant
+// and Revoke decide against the current access.
+func (c *customerBuilder) hasPortalAccess(ctx context.Context, customerID, userID string) (bool, error) {
+	return c.client.HasPortalAccess(client.WithoutCache(ctx), customerID, userID)
+}
+
+// enablePortalAccess turns on portal access for a portal user of a customer.
+func (c *customerBuilder) enablePortalAccess(ctx context.Context, customerID, userID string) (map[string]interface{}, annotations.Annotations, error) {
+	resp, annos, err := c.client.UpdateCustomerPortalAccess(ctx, customerID, userID, true)
+	if err != nil {
+		return nil, annos, err
+	}
+	return map[string]interface{}{
+		"customer_id": customerID,
+		"user_id":     userID,
+		"message":     resp.Message,
+	}, annos, nil
+}
+
+// disablePortalAccess turns off portal access for a portal user of a customer.
+func (c *customerBuilder) disablePortalAccess(ctx context.Context, customerID, userID string) (annotations.Annotations, error) {
+	_, annos, err := c.client.UpdateCustomerPortalAccess(ctx, customerID, userID, false)
+	return annos, err
+}
+
+// Grant enables portal access for a customer portal user.
+func (c *customerBuilder) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) ([]*v2.Grant, annotations.Annotations, error) {
+	return c.members.grant(ctx, principal, entitlement, c.enablePortalAccess)
+}
+
+// Revoke disables portal access for a customer portal user.
+func (c *customerBuilder) Revoke(ctx context.Context, g *v2.Grant) (annotations.Annotations, error) {
+	return c.members.revoke(ctx, g)
+}
+
+// newCustomerBuilder creates a new instance of customerBuilder.
+func newCustomerBuilder(client customerClient) *customerBuilder {
+	c := &customerBuilder{
+		resourceType: customerResourceType,
+		client:       client,
+	}
+	c.members = &membership{
+		slug:          entitlementPortalAccess,
+		name:          "portal access",
+		principalType: customerPortalUserResourceType,
+		has:           c.hasPortalAccess,
+		remove:        c.disablePortalAccess,
+	}
+	return c
+}
@@ -0,0 +1,132 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-zuper/pkg/client"
+	"github.com/conductorone/baton-zuper/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadMockPortalUsers(t *testing.T) []*client.CustomerPortalUser {
+	var users []*client.CustomerPortalUser
+	require.NoError(t, json.Unmarshal([]byte(test.ReadFile("customer_portal_users_success.json")), &users))
+	return users
+}
+
+func TestCustomerBuilder_Grants(t *testing.T) {
+	users := loadMockPortalUsers(t)
+	mockCli := &test.MockClient{
+		GetPortalUsersFunc: func(ctx context.Context, customerUID string, options client.PageOptions) ([]*client.CustomerPortalUser, string, annotations.Annotations, error) {
+			assert.Equal(t, "customer-1", customerUID)
+			return users, "", nil, nil
+		},
+	}
+	builder := newCustomerBuilder(mockCli)
+	customerRes, err := parseIntoCustomerResource(&client.Customer{CustomerUID: "customer-1", CustomerCompanyName: "Acme"})
+	require.NoError(t, err)
+
+	grants, nextToken, _, err := builder.Grants(context.Background(), customerRes, &pagination.Token{})
+	require.NoError(t, err)
+	assert.Empty(t, nextToken)
+	require.Len(t, grants, 1)
+	assert.Equal(t, "portal-1", grants[0].Principal.Id.Resource)
+	assert.Equal(t, customerPortalUserResourceType.Id, grants[0].Principal.Id.ResourceType)
+}
+
+func TestCustomerBuilder_GrantRevoke(t *testing.T) {
+	var calls []bool
+	access := map[string]bool{}
+	mockCli := &test.MockClient{
+		GetPortalUsersFunc: func(ctx context.Context, customerUID string, options client.PageOptions) ([]*client.CustomerPortalUser, string, annotations.Annotations, error) {
+			var users []*client.CustomerPortalUser
+			for userID, hasAccess := range access {
+				users = append(users, &client.CustomerPortalUser{PortalUserUID: userID, CustomerUID: customerUID, HasAccess: hasAccess})
+			}
+			return users, "", nil, nil
+		},
+		UpdatePortalAccessFunc: func(ctx context.Context, customerUID string, portalUserUID string, enabled bool) (*client.UpdatePortalAccessResponse, annotations.Annotations, error) {
+			if portalUserUID == "broken" {
+				return nil, nil, errors.New("mock error")
+			}
+			calls = append(calls, enabled)
+			access[portalUserUID] = enabled
+			return &client.UpdatePortalAccessResponse{Message: "ok"}, nil, nil
+		},
+	}
+	builder := newCustomerBuilder(mockCli)
+	customerRes := &v2.Resource{Id: &v2.ResourceId{ResourceType: customerResourceType.Id, Resource: "customer-1"}}
+	portalUser := &v2.Resource{Id: makeCustomerPortalUserSubjectID("portal-2")}
+	ent := &v2.Entitlement{Resource: customerRes}
+
+	grants, _, err := builder.Grant(context.Background(), portalUser, ent)
+	require.NoError(t, err)
+	require.Len(t, grants, 1)
+
+	grants, annos, err := builder.Grant(context.Background(), portalUser, ent)
+	require.NoError(t, err)
+	assert.Empty(t, grants)
+	assert.True(t, annos.Contains(&v2.GrantAlreadyExists{}))
+
+	_, err = builder.Revoke(context.Background(), &v2.Grant{Entitlement: ent, Principal: portalUser})
+	require.NoError(t, err)
+
+	annos, err = builder.Revoke(context.Background(), &v2.Grant{Entitlement: ent, Principal: portalUser})
+	require.NoError(t, err)
+	assert.True(t, annos.Contains(&v2.GrantAlreadyRevoked{}))
+	assert.Equal(t, []bool{true, false}, calls, "already applied changes are not sent")
+
+	t.Run("rejects internal users", func(t *testing.T) {
+		user := &v2.Resource{Id: makeUserSubjectID("user-1")}
+		_, _, err := builder.Grant(context.Background(), user, ent)
+		assert.Error(t, err)
+		_, err = builder.Revoke(context.Background(), &v2.Grant{Entitlement: ent, Principal: user})
+		assert.Error(t, err)
+	})
+
+	t.Run("client error", func(t *testing.T) {
+		_, _, err := builder.Grant(context.Background(), &v2.Resource{Id: makeCustomerPortalUserSubjectID("broken")}, ent)
+		assert.Error(t, err)
+	})
+
+	t.Run("access check error", func(t *testing.T) {
+		failing := newCustomerBuilder(&test.MockClient{
+			GetPortalUsersFunc: func(ctx context.Context, customerUID string, options client.PageOptions) ([]*client.CustomerPortalUser, string, annotations.Annotations, error) {
+				return nil, "", nil, errors.New("mock error")
+			},
+		})
+		_, _, err := failing.Grant(context.Background(), portalUser, ent)
+		assert.ErrorContains(t, err, "failed to check portal access")
+	})
+}
+
+func TestCustomerPortalUserBuilder_List(t *testing.T) {
+	users := loadMockPortalUsers(t)
+	builder := newCustomerPortalUserBuilder(&test.MockClient{
+		GetPortalUsersFunc: func(ctx context.Context, customerUID string, options client.PageOptions) ([]*client.CustomerPortalUser, string, annotations.Annotations, error) {
+			return users, "", nil, nil
+		},
+	})
+
+	t.Run("without parent returns nothing", func(t *testing.T) {
+		resources, _, _, err := builder.List(context.Background(), nil, &pagination.Token{})
+		require.NoError(t, err)
+		assert.Empty(t, resources)
+	})
+
+	t.Run("lists portal users under customer", func(t *testing.T) {
+		parent := &v2.ResourceId{ResourceType: customerResourceType.Id, Resource: "customer-1"}
+		resources, _, _, err := builder.List(context.Background(), parent, &pagination.Token{})
+		require.NoError(t, err)
+		require.Len(t, resources, 2)
+		assert.Equal(t, "Ana Lopez", resources[0].DisplayName)
+		assert.Equal(t, "customer-1", resources[0].ParentResourceId.Resource)
+	})
+}
@@ -0,0 +1,293 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/types"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// grantExpiryKey is the grant metadata key used to request and report a grant expiry.
+const grantExpiryKey = "expires_at"
+
+// expiringGrant is a time-bound grant made by the connector.
+type expiringGrant struct {
+	ResourceType string    `json:"resource_type"`
+	ResourceID   string    `json:"resource_id"`
+	Entitlement  string    `json:"entitlement"`
+	PrincipalID  string    `json:"principal_id"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// grantExpiryStore persists time-bound grants to a local JSON file so the expiry sweep can revoke them later. The
+// file is the only record of which grants expire, so it must outlive the process: keep it on a persistent volume,
+// not on the ephemeral disk of a stateless or one-shot deployment. The file is re-read before every change, so runs
+// that follow each other may share it, but it takes no lock: only one replica may write to it at a time.
+type grantExpiryStore struct {
+	mu     sync.Mutex
+	path   string
+	grants []expiringGrant
+	// dryRun keeps changes in memory only, so simulated grants are never revoked for real.
+	dryRun bool
+}
+
+// newGrantExpiryStore loads the store at path, starting empty if the file does not exist yet.
+func newGrantExpiryStore(path string) (*grantExpiryStore, error) {
+	s := &grantExpiryStore{path: path}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load replaces the grants in memory with the ones in the file, picking up changes made by earlier runs. A dry-run
+// store keeps its own grants, as the file never sees them. Callers must hold s.mu, except during construction.
+func (s *grantExpiryStore) load() error {
+	if s.dryRun {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.grants = nil
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read grant expiry store: %w", err)
+	}
+	var grants []expiringGrant
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &grants); err != nil {
+			return fmt.Errorf("failed to parse grant expiry store %s: %w", s.path, err)
+		}
+	}
+	s.grants = grants
+	return nil
+}
+
+// save writes the store atomically. Callers must hold s.mu.
+func (s *grantExpiryStore) save() error {
+	if s.dryRun {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.grants, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".grant-expiry-*")
+	if err != nil {
+		return fmt.Errorf("failed to write grant expiry store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write grant expiry store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write grant expiry store: %w", err)
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// matches reports whether g refers to the given grant.
+func (g expiringGrant) matches(resourceID *v2.ResourceId, entitlementSlug string, principalID string) bool {
+	return g.ResourceType == resourceID.ResourceType &&
+		g.ResourceID == resourceID.Resource &&
+		g.Entitlement == entitlementSlug &&
+		g.PrincipalID == principalID
+}
+
+// track records the expiry of a grant made by the connector. A zero expiresAt marks the grant as
+// permanent and clears any previously recorded expiry.
+func (s *grantExpiryStore) track(resourceID *v2.ResourceId, entitlementSlug string, principalID string, expiresAt time.Time) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	grants := s.grants[:0]
+	for _, g := range s.grants {
+		if !g.matches(resourceID, entitlementSlug, principalID) {
+			grants = append(grants, g)
+		}
+	}
+	if !expiresAt.IsZero() {
+		grants = append(grants, expiringGrant{
+			ResourceType: resourceID.ResourceType,
+			ResourceID:   resourceID.Resource,
+			Entitlement:  entitlementSlug,
+			PrincipalID:  principalID,
+			ExpiresAt:    expiresAt.UTC(),
+		})
+	}
+	s.grants = grants
+	return s.save()
+}
+
+// forget removes a grant from the store once it has been revoked.
+func (s *grantExpiryStore) forget(resourceID *v2.ResourceId, entitlementSlug string, principalID string) error {
+	return s.track(resourceID, entitlementSlug, principalID, time.Time{})
+}
+
+// expired returns the grants whose expiry is at or before now.
+func (s *grantExpiryStore) expired(now time.Time) ([]expiringGrant, error) {
+	if s == nil {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	var expired []expiringGrant
+	for _, g := range s.grants {
+		if !now.Before(g.ExpiresAt) {
+			expired = append(expired, g)
+		}
+	}
+	return expired, nil
+}
+
+// grantExpiryContextKey carries the expiry requested on a grant request to the builder that makes the grant.
+type grantExpiryContextKey struct{}
+
+// grantExpiryServer passes the expiry set on grant requests down to the builders. The SDK hands builders only the
+// principal and entitlement of a grant, so the GrantMetadata annotation on the request itself would be lost.
+type grantExpiryServer struct {
+	types.ConnectorServer
+}
+
+func (s *grantExpiryServer) Grant(
+	ctx context.Context,
+	request *v2.GrantManagerServiceGrantRequest,
+) (*v2.GrantManagerServiceGrantResponse, error) {
+	var metadata v2.GrantMetadata
+	annos := annotations.Annotations(request.GetAnnotations())
+	ok, err := annos.Pick(&metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read grant metadata: %w", err)
+	}
+	if ok && metadata.GetMetadata() != nil {
+		if value, ok := metadata.GetMetadata().AsMap()[grantExpiryKey].(string); ok && value != "" {
+			ctx = context.WithValue(ctx, grantExpiryContextKey{}, value)
+		}
+	}
+	return s.ConnectorServer.Grant(ctx, request)
+}
+
+// parseGrantExpiry returns the expiry requested through a GrantMetadata annotation on the grant request, or the zero
+// time when the grant is permanent. The expiry is enforced by the sweep from the store, and the connector only has a
+// store when the sweep is enabled, so a time-bound grant is refused when nothing would revoke it.
+func parseGrantExpiry(ctx context.Context, store *grantExpiryStore) (time.Time, error) {
+	expiresAt, err := requestedGrantExpiry(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !expiresAt.IsZero() && store == nil {
+		return time.Time{}, errors.New("time-bound grants require the grant-expiry-store and expiry-sweep options")
+	}
+	return expiresAt, nil
+}
+
+// requestedGrantExpiry returns the expiry requested through a GrantMetadata annotation on the grant request, or the
+// zero time when the grant is permanent.
+func requestedGrantExpiry(ctx context.Context) (time.Time, error) {
+	value, ok := ctx.Value(grantExpiryContextKey{}).(string)
+	if !ok || value == "" {
+		return time.Time{}, nil
+	}
+
+	expiresAt, err := parseExpiryDate(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !expiresAt.After(time.Now()) {
+		return time.Time{}, fmt.Errorf("grant expiry %s is not in the future", value)
+	}
+	return expiresAt, nil
+}
+
+// grantMetadataWithExpiry adds the expiry to grant metadata when the grant is time-bound.
+func grantMetadataWithExpiry(metadata map[string]interface{}, expiresAt time.Time) map[string]interface{} {
+	if !expiresAt.IsZero() {
+		metadata[grantExpiryKey] = expiresAt.Format(time.RFC3339)
+	}
+	return metadata
+}
+
+// grantRevoker revokes a grant of a single resource type.
+type grantRevoker interface {
+	Revoke(ctx context.Context, g *v2.Grant) (annotations.Annotations, error)
+}
+
+// revokeExpiredGrants revokes every recorded grant whose expiry has passed and logs what was revoked. It carries on
+// past the grants that fail and returns their errors together.
+func revokeExpiredGrants(ctx context.Context, store *grantExpiryStore, revokers map[string]grantRevoker, now time.Time) error {
+	l := ctxzap.Extract(ctx)
+	expired, err := store.expired(now)
+	if err != nil {
+		return err
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+
+	var errs []error
+	revoked := 0
+	for _, g := range expired {
+		resourceID := &v2.ResourceId{ResourceType: g.ResourceType, Resource: g.ResourceID}
+		fields := []zap.Field{
+			zap.String("resource_type", g.ResourceType),
+			zap.String("resource_id", g.ResourceID),
+			zap.String("entitlement", g.Entitlement),
+			zap.String("principal_id", g.PrincipalID),
+			zap.Time("expires_at", g.ExpiresAt),
+		}
+
+		revoker, ok := revokers[g.ResourceType]
+		if !ok {
+			l.Warn("no revoker for expired grant", fields...)
+			errs = append(errs, fmt.Errorf("no revoker for expired %s grant", g.ResourceType))
+			continue
+		}
+		_, err := revoker.Revoke(ctx, &v2.Grant{
+			Entitlement: &v2.Entitlement{
+				Resource: &v2.Resource{Id: resourceID},
+				Slug:     g.Entitlement,
+			},
+			Principal: &v2.Resource{Id: makeUserSubjectID(g.PrincipalID)},
+		})
+		if err != nil {
+			l.Error("failed to revoke expired grant", append(fields, zap.Error(err))...)
+			errs = append(errs, fmt.Errorf("failed to revoke expired grant of %s %s to %s: %w", g.ResourceType, g.ResourceID, g.PrincipalID, err))
+			continue
+		}
+		if err := store.forget(resourceID, g.Entitlement, g.PrincipalID); err != nil {
+			l.Error("failed to update grant expiry store", append(fields, zap.Error(err))...)
+			errs = append(errs, fmt.Errorf("failed to update grant expiry store: %w", err))
+			continue
+		}
+		revoked++
+		l.Info("revoked expired grant", fields...)
+	}
+
+	l.Info("expired grant sweep complete",
+		zap.Int("expired", len(expired)),
+		zap.Int("revoked", revoked),
+		zap.Int("failed", len(expired)-revoked),
+	)
+	return errors.Join(errs...)
+}
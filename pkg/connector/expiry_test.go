@@ -0,0 +1,233 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/types"
+	"github.com/conductorone/baton-zuper/pkg/client"
+	"github.com/conductorone/baton-zuper/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// grantRequestWithExpiry returns a grant request carrying a grant expiry request.
+func grantRequestWithExpiry(t *testing.T, expiresAt string) *v2.GrantManagerServiceGrantRequest {
+	metadata, err := structpb.NewStruct(map[string]interface{}{grantExpiryKey: expiresAt})
+	require.NoError(t, err)
+	return &v2.GrantManagerServiceGrantRequest{
+		Annotations: annotations.New(&v2.GrantMetadata{Metadata: metadata}),
+	}
+}
+
+// ctxWithExpiry returns a context carrying the expiry of a grant request, as grantExpiryServer passes it on.
+func ctxWithExpiry(expiresAt string) context.Context {
+	return context.WithValue(context.Background(), grantExpiryContextKey{}, expiresAt)
+}
+
+// grantContextServer records the context of the grants it receives.
+type grantContextServer struct {
+	types.ConnectorServer
+	ctx context.Context
+}
+
+func (s *grantContextServer) Grant(ctx context.Context, _ *v2.GrantManagerServiceGrantRequest) (*v2.GrantManagerServiceGrantResponse, error) {
+	s.ctx = ctx
+	return &v2.GrantManagerServiceGrantResponse{}, nil
+}
+
+// TestGrantExpiryStore tests recording, persisting and expiring time-bound grants.
+func TestGrantExpiryStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "grants.json")
+	store, err := newGrantExpiryStore(path)
+	require.NoError(t, err)
+
+	team := &v2.ResourceId{ResourceType: teamResourceType.Id, Resource: "team-1"}
+	role := &v2.ResourceId{ResourceType: roleResourceType.Id, Resource: "TEAM_LEADER"}
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	require.NoError(t, store.track(team, entitlementTeamMember, "user-1", now.Add(-time.Hour)))
+	require.NoError(t, store.track(role, assignedEntitlement, "user-1", now.Add(time.Hour)))
+
+	reloaded, err := newGrantExpiryStore(path)
+	require.NoError(t, err)
+	expired, err := reloaded.expired(now)
+	require.NoError(t, err)
+	require.Len(t, expired, 1)
+	assert.Equal(t, "team-1", expired[0].ResourceID)
+	assert.Equal(t, "user-1", expired[0].PrincipalID)
+
+	// Re-granting without an expiry makes the grant permanent.
+	require.NoError(t, reloaded.track(team, entitlementTeamMember, "user-1", time.Time{}))
+	expired, err = reloaded.expired(now)
+	require.NoError(t, err)
+	assert.Empty(t, expired)
+	expired, err = reloaded.expired(now.Add(2 * time.Hour))
+	require.NoError(t, err)
+	assert.Len(t, expired, 1)
+
+	// A store opened earlier sees the changes made through another one.
+	expired, err = store.expired(now.Add(2 * time.Hour))
+	require.NoError(t, err)
+	assert.Len(t, expired, 1)
+	assert.Equal(t, "TEAM_LEADER", expired[0].ResourceID)
+
+	var nilStore *grantExpiryStore
+	assert.NoError(t, nilStore.forget(team, entitlementTeamMember, "user-1"))
+	expired, err = nilStore.expired(now)
+	require.NoError(t, err)
+	assert.Empty(t, expired)
+}
+
+// TestParseGrantExpiry tests reading the expiry requested on a grant request.
+func TestParseGrantExpiry(t *testing.T) {
+	store := &grantExpiryStore{path: filepath.Join(t.TempDir(), "grants.json")}
+	future := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+
+	server := &grantContextServer{}
+	_, err := (&grantExpiryServer{ConnectorServer: server}).Grant(context.Background(), &v2.GrantManagerServiceGrantRequest{})
+	require.NoError(t, err)
+	expiresAt, err := parseGrantExpiry(server.ctx, store)
+	require.NoError(t, err)
+	assert.True(t, expiresAt.IsZero())
+
+	_, err = (&grantExpiryServer{ConnectorServer: server}).Grant(context.Background(), grantRequestWithExpiry(t, future))
+	require.NoError(t, err)
+	expiresAt, err = parseGrantExpiry(server.ctx, store)
+	require.NoError(t, err)
+	assert.Equal(t, future, expiresAt.Format(time.RFC3339))
+
+	_, err = parseGrantExpiry(ctxWithExpiry("2020-01-01"), store)
+	assert.Error(t, err)
+
+	_, err = parseGrantExpiry(ctxWithExpiry("next week"), store)
+	assert.Error(t, err)
+
+	_, err = parseGrantExpiry(ctxWithExpiry(future), nil)
+	assert.Error(t, err)
+}
+
+// TestTeamBuilder_GrantWithExpiry tests that a time-bound membership is recorded and revoked once expired.
+func TestTeamBuilder_GrantWithExpiry(t *testing.T) {
+	var unassigned []string
+	members := map[string]bool{}
+	mockCli := &test.MockClient{
+		GetTeamUsersFunc: func(ctx context.Context, teamID string) ([]*client.ZuperUser, string, annotations.Annotations, error) {
+			var users []*client.ZuperUser
+			for userID := range members {
+				users = append(users, &client.ZuperUser{UserUID: userID})
+			}
+			return users, "", nil, nil
+		},
+		AssignUserToTeamFunc: func(ctx context.Context, teamUID, userUID string) (*client.AssignUserToTeamResponse, annotations.Annotations, error) {
+			members[userUID] = true
+			return &client.AssignUserToTeamResponse{Message: "User assigned to team"}, nil, nil
+		},
+		UnassignUserFromTeamFunc: func(ctx context.Context, teamUID, userUID string) (*client.AssignUserToTeamResponse, annotations.Annotations, error) {
+			unassigned = append(unassigned, teamUID+"/"+userUID)
+			delete(members, userUID)
+			return &client.AssignUserToTeamResponse{Message: "User removed from team"}, nil, nil
+		},
+	}
+	store, err := newGrantExpiryStore(filepath.Join(t.TempDir(), "grants.json"))
+	require.NoError(t, err)
+	builder := &teamBuilder{
+		resourceType: teamResourceType,
+		client:       mockCli,
+		expiries:     store,
+	}
+	teamEnt := &v2.Entitlement{Resource: &v2.Resource{Id: &v2.ResourceId{ResourceType: teamResourceType.Id, Resource: "team-1"}}}
+	userRes := &v2.Resource{Id: &v2.ResourceId{ResourceType: userResourceType.Id, Resource: "user-1"}}
+
+	grants, _, err := builder.Grant(ctxWithExpiry("2099-01-31"), userRes, teamEnt)
+	require.NoError(t, err)
+	require.Len(t, grants, 1)
+
+	var metadata v2.GrantMetadata
+	grantAnnos := annotations.Annotations(grants[0].Annotations)
+	ok, err := grantAnnos.Pick(&metadata)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "2099-01-31T00:00:00Z", metadata.Metadata.AsMap()[grantExpiryKey])
+
+	// Nothing has expired yet.
+	revokers := map[string]grantRevoker{teamResourceType.Id: builder}
+	require.NoError(t, revokeExpiredGrants(context.Background(), store, revokers, time.Now()))
+	assert.Empty(t, unassigned)
+
+	require.NoError(t, revokeExpiredGrants(context.Background(), store, revokers, time.Date(2099, 2, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, []string{"team-1/user-1"}, unassigned)
+	expired, err := store.expired(time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Empty(t, expired)
+}
+
+// TestTeamBuilder_GrantWithExpiryUnverified tests that the expiry of a grant that never shows up in Zuper is not
+// recorded.
+func TestTeamBuilder_GrantWithExpiryUnverified(t *testing.T) {
+	mockCli := &test.MockClient{
+		AssignUserToTeamFunc: func(ctx context.Context, teamUID, userUID string) (*client.AssignUserToTeamResponse, annotations.Annotations, error) {
+			return &client.AssignUserToTeamResponse{Message: "User assigned to team"}, nil, nil
+		},
+		GetTeamUsersFunc: func(ctx context.Context, teamID string) ([]*client.ZuperUser, string, annotations.Annotations, error) {
+			return nil, "", nil, nil
+		},
+	}
+	store, err := newGrantExpiryStore(filepath.Join(t.TempDir(), "grants.json"))
+	require.NoError(t, err)
+	builder := &teamBuilder{
+		resourceType: teamResourceType,
+		client:       mockCli,
+		expiries:     store,
+		verifier:     newTestVerifier(20 * time.Millisecond),
+	}
+	teamEnt := &v2.Entitlement{Resource: &v2.Resource{Id: &v2.ResourceId{ResourceType: teamResourceType.Id, Resource: "team-1"}}}
+	userRes := &v2.Resource{Id: &v2.ResourceId{ResourceType: userResourceType.Id, Resource: "user-1"}}
+
+	_, _, err = builder.Grant(ctxWithExpiry("2099-01-31"), userRes, teamEnt)
+	assert.ErrorIs(t, err, ErrNotConverged)
+	expired, err := store.expired(time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Empty(t, expired)
+}
+
+// TestConnector_ExpirySweep tests that the expiry sweep runs at the start of a sync only when enabled, and that the
+// grant expiry store is refused without it.
+func TestConnector_ExpirySweep(t *testing.T) {
+	ctx := context.Background()
+	var mu sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"type": "success", "data": [], "total_pages": 1, "current_page": 1}`))
+	}))
+	defer server.Close()
+	store := filepath.Join(t.TempDir(), "grants.json")
+
+	_, err := New(ctx, server.URL, "key", WithGrantExpiryStore(store))
+	assert.ErrorContains(t, err, "requires the expiry sweep")
+
+	disabled, err := New(ctx, server.URL, "key")
+	require.NoError(t, err)
+	_, err = disabled.Validate(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, requests, "no sweep without the option")
+
+	enabled, err := New(ctx, server.URL, "key", WithExpirySweep(true), WithGrantExpiryStore(store))
+	require.NoError(t, err)
+	assert.Empty(t, requests, "building the connector does not sweep")
+	_, err = enabled.Validate(ctx)
+	require.NoError(t, err)
+	assert.NotEmpty(t, requests, "the sync start sweeps expired accounts")
+}
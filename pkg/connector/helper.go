@@ -2,6 +2,7 @@ package connector
 
 import (
 	"errors"
+	"time"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/crypto"
@@ -51,3 +52,16 @@ func generateCredentials(credentialOptions *v2.CredentialOptions) (string, error
 	}
 	return password, nil
 }
+
+// parseZuperTime parses a Zuper timestamp, returning false when it is empty or malformed.
+func parseZuperTime(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,138 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/conductorone/baton-zuper/pkg/client"
+)
+
+// listResources returns one page of a top-level resource type, fetching it with fetch and converting each item
+// with parse.
+func listResources[T any](
+	ctx context.Context,
+	resourceType *v2.ResourceType,
+	pToken *pagination.Token,
+	fetch func(ctx context.Context, options client.PageOptions) ([]T, string, annotations.Annotations, error),
+	parse func(T) (*v2.Resource, error),
+) ([]*v2.Resource, string, annotations.Annotations, error) {
+	bag, pageToken, err := parsePageToken(pToken.Token, &v2.ResourceId{ResourceType: resourceType.Id})
+	if err != nil {
+		return nil, "", nil, err
+	}
+	items, nextPageToken, annos, err := fetch(ctx, client.PageOptions{
+		PageSize:  pToken.Size,
+		PageToken: pageToken,
+	})
+	if err != nil {
+		return nil, "", nil, err
+	}
+	var resources []*v2.Resource
+	for _, item := range items {
+		r, err := parse(item)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		resources = append(resources, r)
+	}
+	var outToken string
+	if nextPageToken != "" {
+		outToken, err = bag.NextToken(nextPageToken)
+		if err != nil {
+			return nil, "", nil, err
+		}
+	}
+	return resources, outToken, annos, nil
+}
+
+// membership is an assignment entitlement whose members are added and removed one principal at a time, such as a
+// skill, a territory or a customer portal. It holds the Grant and Revoke logic those builders share: checking the
+// current state first, so that granting a member or revoking a non-member is reported rather than sent to Zuper.
+type membership struct {
+	// slug is the entitlement slug, and name how errors refer to it.
+	slug string
+	name string
+	// principalType is the only resource type the entitlement may be granted to.
+	principalType *v2.ResourceType
+	// has reports whether a principal is a member of a resource.
+	has func(ctx context.Context, resourceID, principalID string) (bool, error)
+	// remove takes a principal's membership away.
+	remove func(ctx context.Context, resourceID, principalID string) (annotations.Annotations, error)
+}
+
+// entitlements returns the membership entitlement of a resource.
+func (m *membership) entitlements(
+	r *v2.Resource,
+	displayName string,
+	description string,
+) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	ent := entitlement.NewAssignmentEntitlement(
+		r,
+		m.slug,
+		entitlement.WithGrantableTo(m.principalType),
+		entitlement.WithDisplayName(displayName),
+		entitlement.WithDescription(description),
+	)
+	return []*v2.Entitlement{ent}, "", annotations.Annotations{}, nil
+}
+
+// memberAdder makes a principal a member of a resource, returning the metadata of the new grant.
+type memberAdder func(ctx context.Context, resourceID, principalID string) (map[string]interface{}, annotations.Annotations, error)
+
+// grant makes principal a member of the entitlement's resource with add, reporting GrantAlreadyExists if it already
+// is one. add is passed per call so that it can carry options of the request, such as a skill's expiry.
+func (m *membership) grant(
+	ctx context.Context,
+	principal *v2.Resource,
+	ent *v2.Entitlement,
+	add memberAdder,
+) ([]*v2.Grant, annotations.Annotations, error) {
+	if principal.Id.ResourceType != m.principalType.Id {
+		return nil, nil, fmt.Errorf("%s can only be granted to %s, got %s", m.name, m.principalType.DisplayName, principal.Id.ResourceType)
+	}
+	resourceID := ent.Resource.Id.Resource
+	principalID := principal.Id.Resource
+
+	member, err := m.has(ctx, resourceID, principalID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to check %s for %s on %s: %w", m.name, principalID, resourceID, err)
+	}
+	if member {
+		return nil, annotations.New(&v2.GrantAlreadyExists{}), nil
+	}
+
+	metadata, annos, err := add(ctx, resourceID, principalID)
+	if err != nil {
+		return nil, annos, fmt.Errorf("failed to grant %s to %s on %s: %w", m.name, principalID, resourceID, err)
+	}
+	grantObj := grant.NewGrant(ent.Resource, m.slug, principal.Id, grant.WithGrantMetadata(metadata))
+	return []*v2.Grant{grantObj}, annos, nil
+}
+
+// revoke takes the grant's principal out of its resource, reporting GrantAlreadyRevoked if it is not a member.
+func (m *membership) revoke(ctx context.Context, g *v2.Grant) (annotations.Annotations, error) {
+	if g.Principal.Id.ResourceType != m.principalType.Id {
+		return nil, fmt.Errorf("%s can only be revoked from %s, got %s", m.name, m.principalType.DisplayName, g.Principal.Id.ResourceType)
+	}
+	resourceID := g.Entitlement.Resource.Id.Resource
+	principalID := g.Principal.Id.Resource
+
+	member, err := m.has(ctx, resourceID, principalID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check %s for %s on %s: %w", m.name, principalID, resourceID, err)
+	}
+	if !member {
+		return annotations.New(&v2.GrantAlreadyRevoked{}), nil
+	}
+
+	annos, err := m.remove(ctx, resourceID, principalID)
+	if err != nil {
+		return annos, fmt.Errorf("failed to revoke %s from %s on %s: %w", m.name, principalID, resourceID, err)
+	}
+	return annos, nil
+}
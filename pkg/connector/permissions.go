@@ -0,0 +1,199 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-zuper/pkg/client"
+)
+
+// Entitlement value representing a permission granted through an access role.
+const (
+	entitlementPermissionGranted = "granted"
+)
+
+// permissionClient defines the client methods required to resolve access role permissions.
+type permissionClient interface {
+	GetAccessRolePermissions(ctx context.Context, accessRoleUID string) ([]*client.ModulePermission, annotations.Annotations, error)
+}
+
+// permission is a single module/action pair and the access roles that allow it.
+type permission struct {
+	Module  string
+	Action  string
+	Holders []*client.AccessRole
+}
+
+// permissionBuilder manages permission resources derived from the access role permission matrix.
+type permissionBuilder struct {
+	resourceType *v2.ResourceType
+	client       permissionClient
+	accessRoles  *accessRoleBuilder
+	mu           sync.RWMutex
+	permissions  map[string]*permission
+	lastFetch    time.Time
+}
+
+// newPermissionBuilder creates a new permissionBuilder instance.
+func newPermissionBuilder(client permissionClient, accessRoles *accessRoleBuilder) *permissionBuilder {
+	return &permissionBuilder{
+		resourceType: permissionResourceType,
+		client:       client,
+		accessRoles:  accessRoles,
+	}
+}
+
+// permissionSeparator separates the module from the action in permission resource IDs. It cannot be ':', which
+// separates the parts of entitlement and grant IDs.
+const permissionSeparator = "."
+
+// permissionIDEscaper percent-encodes the separators of resource, entitlement and grant IDs in a module or action.
+var permissionIDEscaper = strings.NewReplacer("%", "%25", permissionSeparator, "%2E", ":", "%3A", tenantSeparator, "%2F")
+
+// makePermissionID builds the resource ID for a module/action pair.
+func makePermissionID(module string, action string) string {
+	return permissionIDEscaper.Replace(strings.ToLower(module)) + permissionSeparator + permissionIDEscaper.Replace(strings.ToLower(action))
+}
+
+// loadPermissions fetches the permission set of every access role and indexes it by permission.
+func (p *permissionBuilder) loadPermissions(ctx context.Context) error {
+	p.mu.RLock()
+	if time.Since(p.lastFetch) < cacheTTL && p.permissions != nil {
+		p.mu.RUnlock()
+		return nil
+	}
+	p.mu.RUnlock()
+
+	if err := p.accessRoles.loadAccessRoles(ctx); err != nil {
+		return err
+	}
+
+	permissions := make(map[string]*permission)
+	for _, role := range p.accessRoles.roles() {
+		modules, _, err := p.client.GetAccessRolePermissions(ctx, role.AccessRoleUID)
+		if err != nil {
+			return fmt.Errorf("failed to get permissions for access role %s: %w", role.AccessRoleUID, err)
+		}
+		for _, module := range modules {
+			for action, allowed := range module.Actions {
+				if !allowed {
+					continue
+				}
+				id := makePermissionID(module.Module, action)
+				perm, ok := permissions[id]
+				if !ok {
+					perm = &permission{Module: module.Module, Action: action}
+					permissions[id] = perm
+				}
+				perm.Holders = append(perm.Holders, role)
+			}
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.permissions = permissions
+	p.lastFetch = time.Now()
+	return nil
+}
+
+// ResourceType returns the resource type for permissions.
+func (p *permissionBuilder) ResourceType(_ context.Context) *v2.ResourceType {
+	return p.resourceType
+}
+
+// List returns one resource per module/action pair granted by any access role.
+func (p *permissionBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	annos := annotations.Annotations{}
+
+	if err := p.loadPermissions(ctx); err != nil {
+		return nil, "", annos, err
+	}
+
+	p.mu.RLock()
+	ids := make([]string, 0, len(p.permissions))
+	for id := range p.permissions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var resources []*v2.Resource
+	for _, id := range ids {
+		perm := p.permissions[id]
+		permissionResource, err := resource.NewResource(
+			fmt.Sprintf("%s %s", perm.Module, perm.Action),
+			p.resourceType,
+			id,
+			resource.WithDescription(fmt.Sprintf("Allows %s on %s", perm.Action, perm.Module)),
+		)
+		if err != nil {
+			p.mu.RUnlock()
+			return nil, "", annos, fmt.Errorf("failed to create permission resource: %w", err)
+		}
+		resources = append(resources, permissionResource)
+	}
+	p.mu.RUnlock()
+
+	return resources, "", annos, nil
+}
+
+// Entitlements returns a 'granted' entitlement for the given permission resource.
+func (p *permissionBuilder) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	annos := annotations.Annotations{}
+	ent := entitlement.NewPermissionEntitlement(
+		resource,
+		entitlementPermissionGranted,
+		entitlement.WithGrantableTo(accessRoleResourceType, userResourceType),
+		entitlement.WithDisplayName(fmt.Sprintf("%s %s", resource.DisplayName, entitlementPermissionGranted)),
+		entitlement.WithDescription(fmt.Sprintf("Granted %s through an access role", resource.DisplayName)),
+	)
+	return []*v2.Entitlement{ent}, "", annos, nil
+}
+
+// Grants returns a grant to each access role allowing the permission. Grants are expandable so that
+// users assigned to the access role also receive the permission.
+func (p *permissionBuilder) Grants(ctx context.Context, permissionResource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	annos := annotations.Annotations{}
+
+	if err := p.loadPermissions(ctx); err != nil {
+		return nil, "", annos, err
+	}
+
+	p.mu.RLock()
+	perm, ok := p.permissions[permissionResource.Id.Resource]
+	p.mu.RUnlock()
+	if !ok {
+		return nil, "", annos, nil
+	}
+
+	var grants []*v2.Grant
+	for _, role := range perm.Holders {
+		roleResource := &v2.Resource{
+			Id: &v2.ResourceId{
+				ResourceType: accessRoleResourceType.Id,
+				Resource:     role.AccessRoleUID,
+			},
+			DisplayName: role.AccessRoleName,
+		}
+		grantObj := grant.NewGrant(
+			permissionResource,
+			entitlementPermissionGranted,
+			roleResource.Id,
+			grant.WithAnnotation(&v2.GrantExpandable{
+				EntitlementIds: []string{entitlement.NewEntitlementID(roleResource, assignedEntitlement)},
+			}),
+		)
+		grants = append(grants, grantObj)
+	}
+	return grants, "", annos, nil
+}
@@ -0,0 +1,73 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-zuper/pkg/client"
+	"github.com/conductorone/baton-zuper/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPermissionBuilder(t *testing.T) {
+	mockCli := &test.MockClient{
+		GetUsersFunc: func(ctx context.Context, options client.PageOptions) ([]*client.ZuperUser, string, annotations.Annotations, error) {
+			return []*client.ZuperUser{
+				{UserUID: "user-1", AccessRole: &client.AccessRole{AccessRoleUID: "manager", AccessRoleName: "Manager"}},
+				{UserUID: "user-2", AccessRole: &client.AccessRole{AccessRoleUID: "viewer", AccessRoleName: "Viewer"}},
+			}, "", nil, nil
+		},
+		GetAccessRolePermsFunc: func(ctx context.Context, accessRoleUID string) ([]*client.ModulePermission, annotations.Annotations, error) {
+			if accessRoleUID == "manager" {
+				return []*client.ModulePermission{
+					{Module: "INVOICE", Actions: map[string]bool{"view": true, "delete": true}},
+				}, nil, nil
+			}
+			return []*client.ModulePermission{
+				{Module: "INVOICE", Actions: map[string]bool{"view": true, "delete": false}},
+			}, nil, nil
+		},
+	}
+	builder := newPermissionBuilder(mockCli, newAccessRoleBuilder(mockCli, nil, nil))
+	ctx := context.Background()
+
+	resources, _, _, err := builder.List(ctx, nil, &pagination.Token{})
+	require.NoError(t, err)
+	require.Len(t, resources, 2)
+	assert.Equal(t, "invoice.delete", resources[0].Id.Resource)
+	assert.Equal(t, "invoice.view", resources[1].Id.Resource)
+
+	t.Run("delete is granted only to manager, expandable to its assignees", func(t *testing.T) {
+		grants, _, _, err := builder.Grants(ctx, resources[0], &pagination.Token{})
+		require.NoError(t, err)
+		require.Len(t, grants, 1)
+		assert.Equal(t, "manager", grants[0].Principal.Id.Resource)
+
+		var expandable v2.GrantExpandable
+		grantAnnos := annotations.Annotations(grants[0].Annotations)
+		ok, err := grantAnnos.Pick(&expandable)
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, []string{"access-role:manager:assigned"}, expandable.EntitlementIds)
+	})
+
+	t.Run("ids escape separators", func(t *testing.T) {
+		assert.Equal(t, "job%3Anotes.edit%2Eall", makePermissionID("JOB:NOTES", "edit.all"))
+		assert.NotEqual(t, makePermissionID("a.b", "c"), makePermissionID("a", "b.c"))
+		assert.Equal(t, "permission:job%3Anotes.edit:granted", entitlement.NewEntitlementID(
+			&v2.Resource{Id: &v2.ResourceId{ResourceType: permissionResourceType.Id, Resource: makePermissionID("JOB:NOTES", "edit")}},
+			entitlementPermissionGranted,
+		))
+	})
+
+	t.Run("view is granted to both roles", func(t *testing.T) {
+		grants, _, _, err := builder.Grants(ctx, resources[1], &pagination.Token{})
+		require.NoError(t, err)
+		assert.Len(t, grants, 2)
+	})
+}
@@ -0,0 +1,308 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/types"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// apiCallCounter is implemented by connectors that count the Zuper API calls they make.
+type apiCallCounter interface {
+	// takeAPICalls returns the number of calls to each endpoint since the last call.
+	takeAPICalls() map[string]int64
+}
+
+// takeAPICalls returns the number of calls the connector made to each endpoint since the last call.
+func (d *Connector) takeAPICalls() map[string]int64 {
+	return d.client.TakeAPICalls()
+}
+
+// takeAPICalls returns the number of calls all tenants made to each endpoint since the last call.
+func (m *MultiTenantConnector) takeAPICalls() map[string]int64 {
+	calls := make(map[string]int64)
+	for _, name := range m.names {
+		for endpoint, count := range m.tenants[name].takeAPICalls() {
+			calls[endpoint] += count
+		}
+	}
+	return calls
+}
+
+// syncReport summarizes what a sync saw: resource counts, grants, API calls, time spent per resource type and
+// anomalies in the Zuper data.
+type syncReport struct {
+	StartedAt            time.Time        `json:"started_at"`
+	FinishedAt           time.Time        `json:"finished_at"`
+	Resources            map[string]int   `json:"resources"`
+	UsersByStatus        map[string]int   `json:"users_by_status"`
+	GrantsPerEntitlement map[string]int   `json:"grants_per_entitlement"`
+	APICalls             map[string]int64 `json:"api_calls"`
+	BuilderTimeMillis    map[string]int64 `json:"builder_time_ms"`
+	Anomalies            syncAnomalies    `json:"anomalies"`
+}
+
+// syncAnomalies lists the Zuper data that looks inconsistent after a sync.
+type syncAnomalies struct {
+	UsersWithoutRole       []string            `json:"users_without_role"`
+	UsersWithoutAccessRole []string            `json:"users_without_access_role"`
+	TeamUserCountMismatch  []teamCountMismatch `json:"team_user_count_mismatch"`
+}
+
+// teamCountMismatch is a team whose reported user_count differs from the members synced.
+type teamCountMismatch struct {
+	TeamID    string `json:"team_id"`
+	UserCount int    `json:"user_count"`
+	Members   int    `json:"members"`
+}
+
+// syncStats collects what the connector server returns during a sync.
+type syncStats struct {
+	mu                   sync.Mutex
+	startedAt            time.Time
+	resources            map[string]int
+	usersByStatus        map[string]int
+	users                map[string]bool
+	grantsPerEntitlement map[string]int
+	roleHolders          map[string]bool
+	accessRoleHolders    map[string]bool
+	teamUserCounts       map[string]int
+	teamMembers          map[string]int
+	builderTime          map[string]time.Duration
+}
+
+// newSyncStats returns empty stats for the next sync.
+func newSyncStats() *syncStats {
+	return &syncStats{
+		resources:            make(map[string]int),
+		usersByStatus:        make(map[string]int),
+		users:                make(map[string]bool),
+		grantsPerEntitlement: make(map[string]int),
+		roleHolders:          make(map[string]bool),
+		accessRoleHolders:    make(map[string]bool),
+		teamUserCounts:       make(map[string]int),
+		teamMembers:          make(map[string]int),
+		builderTime:          make(map[string]time.Duration),
+	}
+}
+
+// empty reports whether nothing was synced.
+func (s *syncStats) empty() bool {
+	return s.startedAt.IsZero()
+}
+
+// recordTime adds the time taken by a call listing resources of resourceType or their entitlements or grants.
+func (s *syncStats) recordTime(resourceType string, start time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.startedAt.IsZero() {
+		s.startedAt = start
+	}
+	s.builderTime[resourceType] += time.Since(start)
+}
+
+// recordResources records a page of synced resources.
+func (s *syncStats) recordResources(resources []*v2.Resource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range resources {
+		resourceType := r.GetId().GetResourceType()
+		s.resources[resourceType]++
+		switch resourceType {
+		case userResourceType.Id:
+			s.users[r.GetId().GetResource()] = true
+			if trait, err := resource.GetUserTrait(r); err == nil {
+				status := strings.TrimPrefix(trait.GetStatus().GetStatus().String(), "STATUS_")
+				s.usersByStatus[strings.ToLower(status)]++
+			}
+		case teamResourceType.Id:
+			if trait, err := resource.GetGroupTrait(r); err == nil {
+				if count, ok := trait.GetProfile().GetFields()["user_count"]; ok {
+					s.teamUserCounts[r.GetId().GetResource()] = int(count.GetNumberValue())
+				}
+			}
+		}
+	}
+}
+
+// recordGrants records a page of synced grants.
+func (s *syncStats) recordGrants(grants []*v2.Grant) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, g := range grants {
+		s.grantsPerEntitlement[g.GetEntitlement().GetId()]++
+		principal := g.GetPrincipal().GetId()
+		if principal.GetResourceType() != userResourceType.Id {
+			continue
+		}
+		target := g.GetEntitlement().GetResource().GetId()
+		switch target.GetResourceType() {
+		case roleResourceType.Id:
+			s.roleHolders[principal.GetResource()] = true
+		case accessRoleResourceType.Id:
+			s.accessRoleHolders[principal.GetResource()] = true
+		case teamResourceType.Id:
+			s.teamMembers[target.GetResource()]++
+		}
+	}
+}
+
+// report summarizes the stats, with the API calls made during the sync.
+func (s *syncStats) report(apiCalls map[string]int64) *syncReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := &syncReport{
+		StartedAt:            s.startedAt,
+		FinishedAt:           time.Now(),
+		Resources:            s.resources,
+		UsersByStatus:        s.usersByStatus,
+		GrantsPerEntitlement: s.grantsPerEntitlement,
+		APICalls:             apiCalls,
+		BuilderTimeMillis:    make(map[string]int64, len(s.builderTime)),
+	}
+	for resourceType, took := range s.builderTime {
+		r.BuilderTimeMillis[resourceType] = took.Milliseconds()
+	}
+	for userID := range s.users {
+		if !s.roleHolders[userID] {
+			r.Anomalies.UsersWithoutRole = append(r.Anomalies.UsersWithoutRole, userID)
+		}
+		if !s.accessRoleHolders[userID] {
+			r.Anomalies.UsersWithoutAccessRole = append(r.Anomalies.UsersWithoutAccessRole, userID)
+		}
+	}
+	sort.Strings(r.Anomalies.UsersWithoutRole)
+	sort.Strings(r.Anomalies.UsersWithoutAccessRole)
+	for teamID, userCount := range s.teamUserCounts {
+		if members := s.teamMembers[teamID]; members != userCount {
+			r.Anomalies.TeamUserCountMismatch = append(r.Anomalies.TeamUserCountMismatch,
+				teamCountMismatch{TeamID: teamID, UserCount: userCount, Members: members})
+		}
+	}
+	sort.Slice(r.Anomalies.TeamUserCountMismatch, func(i, j int) bool {
+		return r.Anomalies.TeamUserCountMismatch[i].TeamID < r.Anomalies.TeamUserCountMismatch[j].TeamID
+	})
+	return r
+}
+
+// reportingServer is a connector server that summarizes each sync once the SDK cleans up after it.
+type reportingServer struct {
+	types.ConnectorServer
+	counter apiCallCounter
+	path    string
+
+	mu    sync.Mutex
+	stats *syncStats
+}
+
+// NewServer returns the connector server of cb. It passes the expiry set on grant requests to the builders, and at
+// the end of every sync it logs a summary of the sync and, if reportPath is set, writes the summary to that JSON file.
+func NewServer(ctx context.Context, cb connectorbuilder.ConnectorBuilder, reportPath string) (types.ConnectorServer, error) {
+	builderServer, err := connectorbuilder.NewConnector(ctx, cb)
+	if err != nil {
+		return nil, err
+	}
+	server := &grantExpiryServer{ConnectorServer: builderServer}
+	counter, ok := cb.(apiCallCounter)
+	if !ok {
+		return server, nil
+	}
+	return &reportingServer{
+		ConnectorServer: server,
+		counter:         counter,
+		path:            reportPath,
+		stats:           newSyncStats(),
+	}, nil
+}
+
+// currentStats returns the stats of the sync in progress.
+func (s *reportingServer) currentStats() *syncStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+func (s *reportingServer) ListResources(
+	ctx context.Context,
+	req *v2.ResourcesServiceListResourcesRequest,
+) (*v2.ResourcesServiceListResourcesResponse, error) {
+	stats := s.currentStats()
+	defer stats.recordTime(req.GetResourceTypeId(), time.Now())
+	resp, err := s.ConnectorServer.ListResources(ctx, req)
+	if err == nil {
+		stats.recordResources(resp.GetList())
+	}
+	return resp, err
+}
+
+func (s *reportingServer) ListEntitlements(
+	ctx context.Context,
+	req *v2.EntitlementsServiceListEntitlementsRequest,
+) (*v2.EntitlementsServiceListEntitlementsResponse, error) {
+	defer s.currentStats().recordTime(req.GetResource().GetId().GetResourceType(), time.Now())
+	return s.ConnectorServer.ListEntitlements(ctx, req)
+}
+
+func (s *reportingServer) ListGrants(
+	ctx context.Context,
+	req *v2.GrantsServiceListGrantsRequest,
+) (*v2.GrantsServiceListGrantsResponse, error) {
+	stats := s.currentStats()
+	defer stats.recordTime(req.GetResource().GetId().GetResourceType(), time.Now())
+	resp, err := s.ConnectorServer.ListGrants(ctx, req)
+	if err == nil {
+		stats.recordGrants(resp.GetList())
+	}
+	return resp, err
+}
+
+// Cleanup reports on the sync that just finished before the SDK cleans up after it.
+func (s *reportingServer) Cleanup(ctx context.Context, req *v2.ConnectorServiceCleanupRequest) (*v2.ConnectorServiceCleanupResponse, error) {
+	s.mu.Lock()
+	stats := s.stats
+	s.stats = newSyncStats()
+	s.mu.Unlock()
+
+	if !stats.empty() {
+		if err := s.writeReport(ctx, stats.report(s.counter.takeAPICalls())); err != nil {
+			ctxzap.Extract(ctx).Warn("failed to write sync report", zap.Error(err))
+		}
+	}
+	return s.ConnectorServer.Cleanup(ctx, req)
+}
+
+// writeReport logs report and, if a report path is set, writes it to that file.
+func (s *reportingServer) writeReport(ctx context.Context, report *syncReport) error {
+	ctxzap.Extract(ctx).Info("sync report",
+		zap.Time("started_at", report.StartedAt),
+		zap.Time("finished_at", report.FinishedAt),
+		zap.Any("resources", report.Resources),
+		zap.Any("users_by_status", report.UsersByStatus),
+		zap.Any("grants_per_entitlement", report.GrantsPerEntitlement),
+		zap.Any("api_calls", report.APICalls),
+		zap.Any("builder_time_ms", report.BuilderTimeMillis),
+		zap.Any("anomalies", report.Anomalies),
+	)
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write sync report: %w", err)
+	}
+	return nil
+}
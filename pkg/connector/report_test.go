@@ -0,0 +1,126 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/types"
+	grantpkg "github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSyncServer serves fixed resources and grants.
+type fakeSyncServer struct {
+	types.ConnectorServer
+	resources map[string][]*v2.Resource
+	grants    []*v2.Grant
+	cleanedUp bool
+}
+
+func (f *fakeSyncServer) ListResources(
+	_ context.Context,
+	req *v2.ResourcesServiceListResourcesRequest,
+) (*v2.ResourcesServiceListResourcesResponse, error) {
+	return &v2.ResourcesServiceListResourcesResponse{List: f.resources[req.GetResourceTypeId()]}, nil
+}
+
+func (f *fakeSyncServer) ListGrants(
+	_ context.Context,
+	req *v2.GrantsServiceListGrantsRequest,
+) (*v2.GrantsServiceListGrantsResponse, error) {
+	var grants []*v2.Grant
+	for _, g := range f.grants {
+		if g.GetEntitlement().GetResource().GetId().GetResource() == req.GetResource().GetId().GetResource() {
+			grants = append(grants, g)
+		}
+	}
+	return &v2.GrantsServiceListGrantsResponse{List: grants}, nil
+}
+
+func (f *fakeSyncServer) Cleanup(context.Context, *v2.ConnectorServiceCleanupRequest) (*v2.ConnectorServiceCleanupResponse, error) {
+	f.cleanedUp = true
+	return &v2.ConnectorServiceCleanupResponse{}, nil
+}
+
+// fakeCallCounter reports a fixed set of API calls.
+type fakeCallCounter map[string]int64
+
+func (f fakeCallCounter) takeAPICalls() map[string]int64 {
+	return f
+}
+
+// TestReportingServer tests the summary written when a sync is cleaned up.
+func TestReportingServer(t *testing.T) {
+	active, err := resource.NewUserResource("Ann", userResourceType, "u1", []resource.UserTraitOption{
+		resource.WithStatus(v2.UserTrait_Status_STATUS_ENABLED),
+	})
+	require.NoError(t, err)
+	disabled, err := resource.NewUserResource("Bob", userResourceType, "u2", []resource.UserTraitOption{
+		resource.WithStatus(v2.UserTrait_Status_STATUS_DISABLED),
+	})
+	require.NoError(t, err)
+	team, err := resource.NewGroupResource("Field", teamResourceType, "t1", []resource.GroupTraitOption{
+		resource.WithGroupProfile(map[string]interface{}{"user_count": 3}),
+	})
+	require.NoError(t, err)
+	role, err := resource.NewRoleResource("Admin", roleResourceType, "1", nil)
+	require.NoError(t, err)
+
+	fake := &fakeSyncServer{
+		resources: map[string][]*v2.Resource{
+			userResourceType.Id: {active, disabled},
+			teamResourceType.Id: {team},
+			roleResourceType.Id: {role},
+		},
+		grants: []*v2.Grant{
+			grantpkg.NewGrant(team, entitlementTeamMember, active.Id),
+			grantpkg.NewGrant(team, entitlementTeamMember, disabled.Id),
+			grantpkg.NewGrant(role, assignedEntitlement, active.Id),
+		},
+	}
+	path := filepath.Join(t.TempDir(), "report.json")
+	server := &reportingServer{
+		ConnectorServer: fake,
+		counter:         fakeCallCounter{"GET /api/user/all": 2},
+		path:            path,
+		stats:           newSyncStats(),
+	}
+
+	ctx := context.Background()
+	for _, resourceType := range []string{userResourceType.Id, teamResourceType.Id, roleResourceType.Id} {
+		_, err := server.ListResources(ctx, &v2.ResourcesServiceListResourcesRequest{ResourceTypeId: resourceType})
+		require.NoError(t, err)
+	}
+	for _, r := range []*v2.Resource{team, role} {
+		_, err := server.ListGrants(ctx, &v2.GrantsServiceListGrantsRequest{Resource: r})
+		require.NoError(t, err)
+	}
+	_, err = server.Cleanup(ctx, &v2.ConnectorServiceCleanupRequest{})
+	require.NoError(t, err)
+	assert.True(t, fake.cleanedUp)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var report syncReport
+	require.NoError(t, json.Unmarshal(data, &report))
+	assert.Equal(t, map[string]int{"user": 2, "team": 1, "role": 1}, report.Resources)
+	assert.Equal(t, map[string]int{"enabled": 1, "disabled": 1}, report.UsersByStatus)
+	assert.Equal(t, map[string]int{"team:t1:member": 2, "role:1:assigned": 1}, report.GrantsPerEntitlement)
+	assert.Equal(t, map[string]int64{"GET /api/user/all": 2}, report.APICalls)
+	assert.Contains(t, report.BuilderTimeMillis, userResourceType.Id)
+	assert.Equal(t, []string{"u2"}, report.Anomalies.UsersWithoutRole)
+	assert.Equal(t, []string{"u1", "u2"}, report.Anomalies.UsersWithoutAccessRole)
+	assert.Equal(t, []teamCountMismatch{{TeamID: "t1", UserCount: 3, Members: 2}}, report.Anomalies.TeamUserCountMismatch)
+
+	// The next cleanup has nothing to report and leaves the file alone.
+	require.NoError(t, os.Remove(path))
+	_, err = server.Cleanup(ctx, &v2.ConnectorServiceCleanupRequest{})
+	require.NoError(t, err)
+	assert.NoFileExists(t, path)
+}
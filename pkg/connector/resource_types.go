@@ -2,9 +2,15 @@ package connector
 
 import (
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
 )
 
 var (
+	tenantResourceType = &v2.ResourceType{
+		Id:          "tenant",
+		DisplayName: "Tenant",
+		Annotations: annotations.New(&v2.SkipEntitlementsAndGrants{}),
+	}
 	userResourceType = &v2.ResourceType{
 		Id:          "user",
 		DisplayName: "User",
@@ -25,4 +31,34 @@ var (
 		DisplayName: "Team",
 		Traits:      []v2.ResourceType_Trait{v2.ResourceType_TRAIT_GROUP},
 	}
+	permissionResourceType = &v2.ResourceType{
+		Id:          "permission",
+		DisplayName: "Permission",
+	}
+	territoryResourceType = &v2.ResourceType{
+		Id:          "territory",
+		DisplayName: "Territory",
+		Traits:      []v2.ResourceType_Trait{v2.ResourceType_TRAIT_GROUP},
+	}
+	skillResourceType = &v2.ResourceType{
+		Id:          "skill",
+		DisplayName: "Skill",
+	}
+	customerResourceType = &v2.ResourceType{
+		Id:          "customer",
+		DisplayName: "Customer",
+		Traits:      []v2.ResourceType_Trait{v2.ResourceType_TRAIT_GROUP},
+	}
+	customerPortalUserResourceType = &v2.ResourceType{
+		Id:          "customer-portal-user",
+		DisplayName: "Customer Portal User",
+		Traits:      []v2.ResourceType_Trait{v2.ResourceType_TRAIT_USER},
+		Annotations: annotations.New(&v2.SkipEntitlementsAndGrants{}),
+	}
+	apiKeyResourceType = &v2.ResourceType{
+		Id:          "api-key",
+		DisplayName: "API Key",
+		Traits:      []v2.ResourceType_Trait{v2.ResourceType_TRAIT_SECRET},
+		Annotations: annotations.New(&v2.SkipEntitlementsAndGrants{}),
+	}
 )
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"strings"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
@@ -29,10 +30,36 @@ var roleDefinitions = []roleDefinition{
 	{"3", "Field Executive", "Indicates some actions are exclusive for field executives", "FIELD_EXECUTIVE"},
 }
 
+// lookupRole returns the definition of a user's role, matching its key, then its ID, then its name, since Zuper
+// does not always return all three.
+func lookupRole(role *client.Role) (roleDefinition, bool) {
+	if role == nil {
+		return roleDefinition{}, false
+	}
+	for _, definition := range roleDefinitions {
+		if role.RoleKey != "" && definition.RoleKey == role.RoleKey {
+			return definition, true
+		}
+	}
+	for _, definition := range roleDefinitions {
+		if role.RoleUID != "" && definition.ID == role.RoleUID {
+			return definition, true
+		}
+	}
+	for _, definition := range roleDefinitions {
+		if role.RoleName != "" && strings.EqualFold(definition.DisplayName, role.RoleName) {
+			return definition, true
+		}
+	}
+	return roleDefinition{}, false
+}
+
 // roleBuilder manages role resources and their entitlements.
 type roleBuilder struct {
 	resourceType *v2.ResourceType
 	client       *client.Client
+	expiries     *grantExpiryStore
+	verifier     *writeVerifier
 }
 
 // ResourceType returns the resource type managed by this builder.
@@ -91,10 +118,16 @@ func (r *roleBuilder) Grants(ctx context.Context, roleRes *v2.Resource, _ *pagin
 }
 
 // Grant assigns a role to a user if the user does not already have it. Used for role provisioning.
+// A role requested with an expiry is revoked by the expiry sweep once it passes.
 func (r *roleBuilder) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) ([]*v2.Grant, annotations.Annotations, error) {
 	userID := principal.Id.Resource
 	roleKey := entitlement.Resource.Id.Resource
 
+	expiresAt, err := parseGrantExpiry(ctx, r.expiries)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	var roleIDStr string
 	for _, definition := range roleDefinitions {
 		if definition.RoleKey == roleKey {
@@ -110,26 +143,35 @@ func (r *roleBuilder) Grant(ctx context.Context, principal *v2.Resource, entitle
 		return nil, nil, fmt.Errorf("invalid role ID: %s", roleIDStr)
 	}
 
-	user, _, err := r.client.GetUserByID(ctx, userID)
+	user, _, err := r.client.GetUserByID(client.WithoutCache(ctx), userID)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get user: %w", err)
 	}
-	if user.Role != nil && user.Role.RoleUID == roleIDStr {
+	if current, ok := lookupRole(user.Role); ok && current.ID == roleIDStr {
 		return nil, annotations.New(&v2.GrantAlreadyExists{}), nil
 	}
 
-	resp, annos, err := r.client.UpdateUserRole(ctx, userID, roleID)
+	resp, annos, err := r.client.UpdateUserRoleIfUnchanged(ctx, user, roleID)
 	if err != nil {
 		return nil, annos, fmt.Errorf("failed to update user role: %w", err)
 	}
+	err = r.verifier.await(ctx, fmt.Sprintf("user %s does not have role %s", userID, roleKey), func(ctx context.Context) (bool, error) {
+		return r.hasRole(ctx, userID, roleIDStr)
+	})
+	if err != nil {
+		return nil, annos, err
+	}
+	if err := r.expiries.track(entitlement.Resource.Id, assignedEntitlement, userID, expiresAt); err != nil {
+		return nil, annos, fmt.Errorf("failed to record role expiry: %w", err)
+	}
 
 	grantObj := grant.NewGrant(
 		entitlement.Resource,
 		assignedEntitlement,
 		principal.Id,
-		grant.WithGrantMetadata(map[string]interface{}{
+		grant.WithGrantMetadata(grantMetadataWithExpiry(map[string]interface{}{
 			"message": resp.Message,
-		}),
+		}, expiresAt)),
 	)
 	return []*v2.Grant{grantObj}, annos, nil
 }
@@ -150,28 +192,54 @@ func (r *roleBuilder) Revoke(ctx context.Context, g *v2.Grant) (annotations.Anno
 		return nil, fmt.Errorf("role ID not found for key: %s", roleKey)
 	}
 
-	user, _, err := r.client.GetUserByID(ctx, userID)
+	user, _, err := r.client.GetUserByID(client.WithoutCache(ctx), userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
 	// Si el usuario ya tiene el rol por defecto (3), retornar GrantAlreadyRevoked
-	if user.Role.RoleKey == "FIELD_EXECUTIVE" {
+	current, known := lookupRole(user.Role)
+	if user.Role == nil || current.RoleKey == "FIELD_EXECUTIVE" {
+		return annotations.New(&v2.GrantAlreadyRevoked{}), nil
+	}
+	// The user has since moved to another role; leave it in place.
+	if known && current.RoleKey != roleKey {
 		return annotations.New(&v2.GrantAlreadyRevoked{}), nil
 	}
 
 	defaultRoleID := 3 // Field Executive
-	_, annos, err := r.client.UpdateUserRole(ctx, userID, defaultRoleID)
+	_, annos, err := r.client.UpdateUserRoleIfUnchanged(ctx, user, defaultRoleID)
 	if err != nil {
 		return annos, fmt.Errorf("failed to set default role: %w", err)
 	}
+	err = r.verifier.await(ctx, fmt.Sprintf("user %s still has role %s", userID, roleKey), func(ctx context.Context) (bool, error) {
+		return r.hasRole(ctx, userID, strconv.Itoa(defaultRoleID))
+	})
+	if err != nil {
+		return annos, err
+	}
+	if err := r.expiries.forget(g.Entitlement.Resource.Id, assignedEntitlement, userID); err != nil {
+		return annos, fmt.Errorf("failed to clear role expiry: %w", err)
+	}
 	return annos, nil
 }
 
 // newRoleBuilder creates a new instance of roleBuilder.
-func newRoleBuilder(client *client.Client) *roleBuilder {
+func newRoleBuilder(client *client.Client, expiries *grantExpiryStore, verifier *writeVerifier) *roleBuilder {
 	return &roleBuilder{
 		resourceType: roleResourceType,
 		client:       client,
+		expiries:     expiries,
+		verifier:     verifier,
+	}
+}
+
+// hasRole reads the user back from Zuper to check whether it holds the role with the given ID.
+func (r *roleBuilder) hasRole(ctx context.Context, userID, roleID string) (bool, error) {
+	user, _, err := r.client.GetUserByID(client.WithoutCache(ctx), userID)
+	if err != nil {
+		return false, err
 	}
+	current, ok := lookupRole(user.Role)
+	return ok && current.ID == roleID, nil
 }
@@ -0,0 +1,61 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// sagaStep is a completed step of a saga and the action that undoes it.
+type sagaStep struct {
+	description string
+	compensate  func(ctx context.Context) error
+}
+
+// saga tracks the completed steps of a multi-step operation so that they can be undone if a later step fails.
+type saga struct {
+	name  string
+	steps []sagaStep
+}
+
+// newSaga starts a saga for the named operation.
+func newSaga(name string) *saga {
+	return &saga{name: name}
+}
+
+// done records a completed step and the action that undoes it.
+func (s *saga) done(description string, compensate func(ctx context.Context) error) {
+	s.steps = append(s.steps, sagaStep{description: description, compensate: compensate})
+}
+
+// rollback undoes the completed steps in reverse order after cause made the operation fail. The returned error
+// wraps cause and lists what was undone and anything that could not be undone.
+func (s *saga) rollback(ctx context.Context, cause error) error {
+	l := ctxzap.Extract(ctx)
+	// Roll back even if the request that started the saga was cancelled.
+	ctx = context.WithoutCancel(ctx)
+
+	var undone, failed []string
+	for i := len(s.steps) - 1; i >= 0; i-- {
+		step := s.steps[i]
+		if err := step.compensate(ctx); err != nil {
+			l.Error("failed to roll back step", zap.String("operation", s.name), zap.String("step", step.description), zap.Error(err))
+			failed = append(failed, fmt.Sprintf("%s (%v)", step.description, err))
+			continue
+		}
+		l.Info("rolled back step", zap.String("operation", s.name), zap.String("step", step.description))
+		undone = append(undone, step.description)
+	}
+
+	msg := fmt.Sprintf("%s failed", s.name)
+	if len(undone) > 0 {
+		msg += fmt.Sprintf("; rolled back: %s", strings.Join(undone, ", "))
+	}
+	if len(failed) > 0 {
+		msg += fmt.Sprintf("; could not roll back: %s", strings.Join(failed, ", "))
+	}
+	return fmt.Errorf("%s: %w", msg, cause)
+}
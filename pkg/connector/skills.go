@@ -0,0 +1,165 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-zuper/pkg/client"
+)
+
+// Entitlement value representing a skill or certification held by a user.
+const (
+	entitlementHasSkill = "has_skill"
+)
+
+type skillClientInterface interface {
+	GetSkills(ctx context.Context, options client.PageOptions) ([]*client.Skill, string, annotations.Annotations, error)
+	GetSkillUsers(ctx context.Context, skillUID string) ([]*client.UserSkill, annotations.Annotations, error)
+	AssignSkillToUser(ctx context.Context, skillUID, userUID, expiresAt string) (*client.AssignSkillToUserResponse, annotations.Annotations, error)
+	UnassignSkillFromUser(ctx context.Context, skillUID, userUID string) (*client.AssignSkillToUserResponse, annotations.Annotations, error)
+}
+
+// skillBuilder is a builder for skill resources.
+type skillBuilder struct {
+	resourceType *v2.ResourceType
+	client       skillClientInterface
+	members      *membership
+}
+
+func (s *skillBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return skillResourceType
+}
+
+// parseIntoSkillResource converts a Skill into a Baton v2.Resource.
+func parseIntoSkillResource(skill *client.Skill) (*v2.Resource, error) {
+	var opts []resource.ResourceOption
+	if skill.SkillDescription != "" {
+		opts = append(opts, resource.WithDescription(skill.SkillDescription))
+	}
+	return resource.NewResource(
+		skill.SkillName,
+		skillResourceType,
+		skill.SkillUID,
+		opts...,
+	)
+}
+
+// List returns the skills as Baton resources, with pagination.
+func (s *skillBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	return listResources(ctx, skillResourceType, pToken, s.client.GetSkills, parseIntoSkillResource)
+}
+
+// Entitlements returns a "has_skill" entitlement for each skill, grantable to users.
+func (s *skillBuilder) Entitlements(ctx context.Context, skillResource *v2.Resource, pToken *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return s.members.entitlements(
+		skillResource,
+		fmt.Sprintf("Has %s", skillResource.DisplayName),
+		fmt.Sprintf("Holds the %s skill", skillResource.DisplayName),
+	)
+}
+
+// Grants returns grants for the "has_skill" entitlement for each user holding the skill.
+func (s *skillBuilder) Grants(ctx context.Context, skillResource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	annos := annotations.Annotations{}
+	skillID := skillResource.Id.Resource
+	holders, _, err := s.client.GetSkillUsers(ctx, skillID)
+	if err != nil {
+		return nil, "", annos, fmt.Errorf("failed to get users for skill %s: %w", skillID, err)
+	}
+	var grants []*v2.Grant
+	for _, holder := range holders {
+		metadata := map[string]interface{}{
+			"skill_id":   skillID,
+			"skill_name": skillResource.DisplayName,
+			"user_id":    holder.UserUID,
+			"username":   holder.Email,
+		}
+		if holder.CertifiedAt != "" {
+			metadata["certified_at"] = holder.CertifiedAt
+		}
+		if holder.ExpiresAt != "" {
+			metadata["expires_at"] = holder.ExpiresAt
+		}
+		grantObj := grant.NewGrant(
+			skillResource,
+			entitlementHasSkill,
+			makeUserSubjectID(holder.UserUID),
+			grant.WithGrantMetadata(metadata),
+		)
+		grants = append(grants, grantObj)
+	}
+	return grants, "", annos, nil
+}
+
+// hasSkill checks if a user already holds a skill, reading past the HTTP cache so Grant and Revoke decide against
+// the current holders.
+func (s *skillBuilder) hasSkill(ctx context.Context, skillID, userID string) (bool, error) {
+	holders, _, err := s.client.GetSkillUsers(client.WithoutCache(ctx), skillID)
+	if err != nil {
+		return false, err
+	}
+	for _, holder := range holders {
+		if holder.UserUID == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// removeSkill takes a skill away from a user.
+func (s *skillBuilder) removeSkill(ctx context.Context, skillID, userID string) (annotations.Annotations, error) {
+	_, annos, err := s.client.UnassignSkillFromUser(ctx, skillID, userID)
+	return annos, err
+}
+
+// Grant assigns a skill to a user. Used for skill provisioning.
+// A skill requested with an expiry is assigned with it, and Zuper lets the skill lapse once it passes.
+func (s *skillBuilder) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) ([]*v2.Grant, annotations.Annotations, error) {
+	expiresAt, err := requestedGrantExpiry(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var expiry string
+	if !expiresAt.IsZero() {
+		expiry = expiresAt.Format(time.RFC3339)
+	}
+
+	return s.members.grant(ctx, principal, entitlement, func(ctx context.Context, skillID, userID string) (map[string]interface{}, annotations.Annotations, error) {
+		resp, annos, err := s.client.AssignSkillToUser(ctx, skillID, userID, expiry)
+		if err != nil {
+			return nil, annos, err
+		}
+		return grantMetadataWithExpiry(map[string]interface{}{
+			"skill_id": skillID,
+			"user_id":  userID,
+			"message":  resp.Message,
+		}, expiresAt), annos, nil
+	})
+}
+
+// Revoke removes a skill from a user. Used for skill deprovisioning.
+func (s *skillBuilder) Revoke(ctx context.Context, g *v2.Grant) (annotations.Annotations, error) {
+	return s.members.revoke(ctx, g)
+}
+
+// newSkillBuilder creates a new instance of skillBuilder.
+func newSkillBuilder(client skillClientInterface) *skillBuilder {
+	s := &skillBuilder{
+		resourceType: skillResourceType,
+		client:       client,
+	}
+	s.members = &membership{
+		slug:          entitlementHasSkill,
+		name:          "skill",
+		principalType: userResourceType,
+		has:           s.hasSkill,
+		remove:        s.removeSkill,
+	}
+	return s
+}
@@ -0,0 +1,108 @@
+package connector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-zuper/pkg/client"
+	"github.com/conductorone/baton-zuper/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSkillBuilder_Grants tests that skill holders are synced with expiry metadata.
+func TestSkillBuilder_Grants(t *testing.T) {
+	mockCli := &test.MockClient{
+		GetSkillUsersFunc: func(ctx context.Context, skillUID string) ([]*client.UserSkill, annotations.Annotations, error) {
+			return []*client.UserSkill{
+				{UserUID: "user-1", ExpiresAt: "2026-01-31T00:00:00.000Z"},
+				{UserUID: "user-2"},
+			}, nil, nil
+		},
+	}
+	builder := newSkillBuilder(mockCli)
+	skillRes, err := parseIntoSkillResource(&client.Skill{SkillUID: "hvac", SkillName: "HVAC"})
+	require.NoError(t, err)
+
+	grants, _, _, err := builder.Grants(context.Background(), skillRes, &pagination.Token{})
+	require.NoError(t, err)
+	require.Len(t, grants, 2)
+
+	var metadata v2.GrantMetadata
+	grantAnnos := annotations.Annotations(grants[0].Annotations)
+	ok, err := grantAnnos.Pick(&metadata)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "2026-01-31T00:00:00.000Z", metadata.Metadata.AsMap()["expires_at"])
+
+	grantAnnos = annotations.Annotations(grants[1].Annotations)
+	_, err = grantAnnos.Pick(&metadata)
+	require.NoError(t, err)
+	assert.NotContains(t, metadata.Metadata.AsMap(), "expires_at")
+}
+
+// TestSkillBuilder_GrantRevoke tests provisioning and deprovisioning of skills.
+func TestSkillBuilder_GrantRevoke(t *testing.T) {
+	holders := map[string]bool{}
+	var expiries []string
+	mockCli := &test.MockClient{
+		GetSkillUsersFunc: func(ctx context.Context, skillUID string) ([]*client.UserSkill, annotations.Annotations, error) {
+			var users []*client.UserSkill
+			for id := range holders {
+				users = append(users, &client.UserSkill{UserUID: id})
+			}
+			return users, nil, nil
+		},
+		AssignSkillFunc: func(ctx context.Context, skillUID, userUID, expiresAt string) (*client.AssignSkillToUserResponse, annotations.Annotations, error) {
+			holders[userUID] = true
+			expiries = append(expiries, expiresAt)
+			return &client.AssignSkillToUserResponse{Message: "assigned"}, nil, nil
+		},
+		UnassignSkillFunc: func(ctx context.Context, skillUID, userUID string) (*client.AssignSkillToUserResponse, annotations.Annotations, error) {
+			delete(holders, userUID)
+			return &client.AssignSkillToUserResponse{Message: "removed"}, nil, nil
+		},
+	}
+	builder := newSkillBuilder(mockCli)
+	ent := &v2.Entitlement{Resource: &v2.Resource{Id: &v2.ResourceId{ResourceType: skillResourceType.Id, Resource: "hvac"}}}
+	user := &v2.Resource{Id: makeUserSubjectID("user-1")}
+
+	grants, _, err := builder.Grant(context.Background(), user, ent)
+	require.NoError(t, err)
+	assert.Len(t, grants, 1)
+
+	_, annos, err := builder.Grant(context.Background(), user, ent)
+	require.NoError(t, err)
+	assert.True(t, annos.Contains(&v2.GrantAlreadyExists{}))
+
+	_, err = builder.Revoke(context.Background(), &v2.Grant{Entitlement: ent, Principal: user})
+	require.NoError(t, err)
+	assert.Empty(t, holders)
+
+	annos, err = builder.Revoke(context.Background(), &v2.Grant{Entitlement: ent, Principal: user})
+	require.NoError(t, err)
+	assert.True(t, annos.Contains(&v2.GrantAlreadyRevoked{}))
+
+	t.Run("with expiry", func(t *testing.T) {
+		expiresAt := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second).Format(time.RFC3339)
+		grants, _, err := builder.Grant(ctxWithExpiry(expiresAt), user, ent)
+		require.NoError(t, err)
+		require.Len(t, grants, 1)
+		assert.Equal(t, []string{"", expiresAt}, expiries, "the requested expiry is sent to Zuper")
+		metadata := &v2.GrantMetadata{}
+		grantAnnos := annotations.Annotations(grants[0].Annotations)
+		ok, err := grantAnnos.Pick(metadata)
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, expiresAt, metadata.Metadata.Fields[grantExpiryKey].GetStringValue())
+	})
+
+	t.Run("expiry in the past", func(t *testing.T) {
+		_, _, err := builder.Grant(ctxWithExpiry("2000-01-01"), &v2.Resource{Id: makeUserSubjectID("user-2")}, ent)
+		assert.ErrorContains(t, err, "not in the future")
+	})
+}
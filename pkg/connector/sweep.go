@@ -0,0 +1,31 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// sweepExpired deactivates the accounts and revokes the time-bound grants of the tenant whose expiry is at or
+// before now.
+func (d *Connector) sweepExpired(ctx context.Context, now time.Time) error {
+	usersErr := newUserBuilder(d.client, d.classifier, nil, d.sweep).deactivateExpiredUsers(ctx, now)
+	grantsErr := revokeExpiredGrants(ctx, d.expiries, map[string]grantRevoker{
+		teamResourceType.Id:       newTeamBuilder(d.client, d.expiries, d.verifier),
+		roleResourceType.Id:       newRoleBuilder(d.client, d.expiries, d.verifier),
+		accessRoleResourceType.Id: newAccessRoleBuilder(d.client, d.expiries, d.verifier),
+	}, now)
+	return errors.Join(usersErr, grantsErr)
+}
+
+// runExpirySweep sweeps the tenant before a sync. The sweep is the only place the connector acts on expiries, and
+// the sync itself only reports them. Failures are logged rather than returned so that one account or grant Zuper
+// refuses to change does not block every sync; the next sync tries it again.
+func (d *Connector) runExpirySweep(ctx context.Context, now time.Time) {
+	if err := d.sweepExpired(ctx, now); err != nil {
+		ctxzap.Extract(ctx).Error("expiry sweep failed", zap.String("tenant", d.tenant), zap.Error(err))
+	}
+}
@@ -3,6 +3,7 @@ package connector
 import (
 	"context"
 	"fmt"
+	"iter"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
@@ -20,7 +21,8 @@ const (
 
 type teamsClientInterface interface {
 	GetTeams(ctx context.Context, options client.PageOptions) ([]*client.Team, string, annotations.Annotations, error)
-	GetTeamUsers(ctx context.Context, teamID string) ([]*client.ZuperUser, string, annotations.Annotations, error)
+	AllTeamMembers(ctx context.Context, teamID string, annos *annotations.Annotations) iter.Seq2[*client.ZuperUser, error]
+	IsUserInTeam(ctx context.Context, teamUID string, userUID string) (bool, error)
 	AssignUserToTeam(ctx context.Context, teamUID, userUID string) (*client.AssignUserToTeamResponse, annotations.Annotations, error)
 	UnassignUserFromTeam(ctx context.Context, teamUID, userUID string) (*client.AssignUserToTeamResponse, annotations.Annotations, error)
 }
@@ -29,6 +31,8 @@ type teamsClientInterface interface {
 type teamBuilder struct {
 	resourceType *v2.ResourceType
 	client       teamsClientInterface
+	expiries     *grantExpiryStore
+	verifier     *writeVerifier
 }
 
 func (t *teamBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
@@ -105,12 +109,11 @@ func (t *teamBuilder) Entitlements(ctx context.Context, teamResource *v2.Resourc
 func (t *teamBuilder) Grants(ctx context.Context, teamResource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
 	annos := annotations.Annotations{}
 	teamID := teamResource.Id.Resource
-	users, _, _, err := t.client.GetTeamUsers(ctx, teamID)
-	if err != nil {
-		return nil, "", annos, fmt.Errorf("failed to get team users for %s: %w", teamID, err)
-	}
 	var grants []*v2.Grant
-	for _, user := range users {
+	for user, err := range t.client.AllTeamMembers(ctx, teamID, &annos) {
+		if err != nil {
+			return nil, "", annos, fmt.Errorf("failed to get team users for %s: %w", teamID, err)
+		}
 		userResource := &v2.Resource{
 			Id: &v2.ResourceId{
 				ResourceType: userResourceType.Id,
@@ -134,42 +137,57 @@ func (t *teamBuilder) Grants(ctx context.Context, teamResource *v2.Resource, _ *
 }
 
 // newTeamBuilder creates a new instance of teamBuilder.
-func newTeamBuilder(client *client.Client) *teamBuilder {
+func newTeamBuilder(client *client.Client, expiries *grantExpiryStore, verifier *writeVerifier) *teamBuilder {
 	return &teamBuilder{
 		resourceType: teamResourceType,
 		client:       client,
+		expiries:     expiries,
+		verifier:     verifier,
 	}
 }
 
 // Grant assigns a user to a team as a member. Used for team membership provisioning.
+// A membership requested with an expiry is revoked by the expiry sweep once it passes.
 func (t *teamBuilder) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) ([]*v2.Grant, annotations.Annotations, error) {
 	teamID := entitlement.Resource.Id.Resource
 	userID := principal.Id.Resource
 
+	expiresAt, err := parseGrantExpiry(ctx, t.expiries)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// Validate if the user is already a member of the team.
-	if client, ok := t.client.(*client.Client); ok {
-		inTeam, err := client.IsUserInTeam(ctx, teamID, userID)
-		if err != nil {
-			return nil, nil, fmt.Errorf("failed to check if user is in team: %w", err)
-		}
-		if inTeam {
-			return nil, annotations.New(&v2.GrantAlreadyExists{}), nil
-		}
+	inTeam, err := t.client.IsUserInTeam(client.WithoutCache(ctx), teamID, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to check if user is in team: %w", err)
+	}
+	if inTeam {
+		return nil, annotations.New(&v2.GrantAlreadyExists{}), nil
 	}
 
 	resp, annos, err := t.client.AssignUserToTeam(ctx, teamID, userID)
 	if err != nil {
 		return nil, annos, fmt.Errorf("failed to assign user %s to team %s: %w", userID, teamID, err)
 	}
+	err = t.verifier.await(ctx, fmt.Sprintf("user %s is not a member of team %s", userID, teamID), func(ctx context.Context) (bool, error) {
+		return t.client.IsUserInTeam(ctx, teamID, userID)
+	})
+	if err != nil {
+		return nil, annos, err
+	}
+	if err := t.expiries.track(entitlement.Resource.Id, entitlementTeamMember, userID, expiresAt); err != nil {
+		return nil, annos, fmt.Errorf("failed to record team membership expiry: %w", err)
+	}
 	grantObj := grant.NewGrant(
 		entitlement.Resource,
 		entitlementTeamMember,
 		principal.Id,
-		grant.WithGrantMetadata(map[string]interface{}{
+		grant.WithGrantMetadata(grantMetadataWithExpiry(map[string]interface{}{
 			"team_id": teamID,
 			"user_id": userID,
 			"message": resp.Message,
-		}),
+		}, expiresAt)),
 	)
 	return []*v2.Grant{grantObj}, annos, nil
 }
@@ -180,19 +198,27 @@ func (t *teamBuilder) Revoke(ctx context.Context, g *v2.Grant) (annotations.Anno
 	userID := g.Principal.Id.Resource
 
 	// Validar si el usuario está en el equipo antes de intentar removerlo
-	if client, ok := t.client.(*client.Client); ok {
-		inTeam, err := client.IsUserInTeam(ctx, teamID, userID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to check if user is in team: %w", err)
-		}
-		if !inTeam {
-			return annotations.New(&v2.GrantAlreadyRevoked{}), nil
-		}
+	inTeam, err := t.client.IsUserInTeam(client.WithoutCache(ctx), teamID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if user is in team: %w", err)
+	}
+	if !inTeam {
+		return annotations.New(&v2.GrantAlreadyRevoked{}), nil
 	}
 
 	_, annos, err := t.client.UnassignUserFromTeam(ctx, teamID, userID)
 	if err != nil {
 		return annos, fmt.Errorf("failed to unassign user %s from team %s: %w", userID, teamID, err)
 	}
+	err = t.verifier.await(ctx, fmt.Sprintf("user %s is still a member of team %s", userID, teamID), func(ctx context.Context) (bool, error) {
+		member, err := t.client.IsUserInTeam(ctx, teamID, userID)
+		return !member, err
+	})
+	if err != nil {
+		return annos, err
+	}
+	if err := t.expiries.forget(g.Entitlement.Resource.Id, entitlementTeamMember, userID); err != nil {
+		return annos, fmt.Errorf("failed to clear team membership expiry: %w", err)
+	}
 	return annos, nil
 }
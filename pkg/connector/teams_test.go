@@ -229,6 +229,9 @@ func TestTeamBuilder_Revoke(t *testing.T) {
 		UnassignUserFromTeamFunc: func(ctx context.Context, teamUID, userUID string) (*client.AssignUserToTeamResponse, annotations.Annotations, error) {
 			return &client.AssignUserToTeamResponse{Message: "User unassigned from team"}, nil, nil
 		},
+		GetTeamUsersFunc: func(ctx context.Context, teamID string) ([]*client.ZuperUser, string, annotations.Annotations, error) {
+			return []*client.ZuperUser{{UserUID: "user-1"}}, "", nil, nil
+		},
 	}
 	builder := &teamBuilder{
 		resourceType: teamResourceType,
@@ -249,6 +252,9 @@ func TestTeamBuilder_Revoke_Error(t *testing.T) {
 		UnassignUserFromTeamFunc: func(ctx context.Context, teamUID, userUID string) (*client.AssignUserToTeamResponse, annotations.Annotations, error) {
 			return nil, nil, errors.New("mock unassign error")
 		},
+		GetTeamUsersFunc: func(ctx context.Context, teamID string) ([]*client.ZuperUser, string, annotations.Annotations, error) {
+			return []*client.ZuperUser{{UserUID: "user-1"}}, "", nil, nil
+		},
 	}
 	builder := &teamBuilder{
 		resourceType: teamResourceType,
@@ -262,3 +268,34 @@ func TestTeamBuilder_Revoke_Error(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, annos)
 }
+
+// TestTeamBuilder_GrantRevoke_Idempotent tests that memberships already in place are not changed again.
+func TestTeamBuilder_GrantRevoke_Idempotent(t *testing.T) {
+	mockCli := &test.MockClient{
+		GetTeamUsersFunc: func(ctx context.Context, teamID string) ([]*client.ZuperUser, string, annotations.Annotations, error) {
+			return []*client.ZuperUser{{UserUID: "user-1"}}, "", nil, nil
+		},
+		AssignUserToTeamFunc: func(ctx context.Context, teamUID, userUID string) (*client.AssignUserToTeamResponse, annotations.Annotations, error) {
+			t.Fatal("member assigned again")
+			return nil, nil, nil
+		},
+		UnassignUserFromTeamFunc: func(ctx context.Context, teamUID, userUID string) (*client.AssignUserToTeamResponse, annotations.Annotations, error) {
+			t.Fatal("non-member unassigned")
+			return nil, nil, nil
+		},
+	}
+	builder := &teamBuilder{
+		resourceType: teamResourceType,
+		client:       mockCli,
+	}
+	ent := &v2.Entitlement{Resource: &v2.Resource{Id: &v2.ResourceId{ResourceType: teamResourceType.Id, Resource: "team-1"}}}
+
+	grants, annos, err := builder.Grant(context.Background(), &v2.Resource{Id: makeUserSubjectID("user-1")}, ent)
+	require.NoError(t, err)
+	assert.Empty(t, grants)
+	assert.True(t, annos.Contains(&v2.GrantAlreadyExists{}))
+
+	annos, err = builder.Revoke(context.Background(), &v2.Grant{Entitlement: ent, Principal: &v2.Resource{Id: makeUserSubjectID("user-2")}})
+	require.NoError(t, err)
+	assert.True(t, annos.Contains(&v2.GrantAlreadyRevoked{}))
+}
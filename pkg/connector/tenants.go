@@ -0,0 +1,682 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	grantpkg "github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-zuper/pkg/client"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// tenantSeparator separates the tenant name from the Zuper ID in the resource IDs of a MultiTenantConnector.
+const tenantSeparator = "/"
+
+// tenantProfileField is the account creation field naming the tenant a new account is created in.
+const tenantProfileField = "tenant"
+
+// tenantEnv is the environment variable naming the tenant whose API key command runs.
+const tenantEnv = "ZUPER_TENANT"
+
+// Tenant is a Zuper account synced by a MultiTenantConnector.
+// Either the API URL or the company login name, used to look up the API URL, must be set. The API key is either
+// given in ApiKey or loaded from ApiKeyFile or ApiKeyCommand, which are read again when Zuper rejects the key.
+// ApiKeyCommand runs with the tenant name in the ZUPER_TENANT environment variable.
+type Tenant struct {
+	Name          string `json:"name"`
+	ApiUrl        string `json:"api_url"`
+	CompanyName   string `json:"company_name"`
+	ApiKey        string `json:"api_key"`
+	ApiKeyFile    string `json:"api_key_file"`
+	ApiKeyCommand string `json:"api_key_command"`
+}
+
+// keySource returns the source the API key of the tenant is loaded from, or nil when the key is given directly.
+func (t Tenant) keySource() client.APIKeySource {
+	switch {
+	case t.ApiKeyFile != "":
+		return client.APIKeyFromFile(t.ApiKeyFile)
+	case t.ApiKeyCommand != "":
+		return client.APIKeyFromCommand(t.ApiKeyCommand, tenantEnv+"="+t.Name)
+	default:
+		return nil
+	}
+}
+
+// keyFields returns the number of ways the API key of the tenant is given.
+func (t Tenant) keyFields() int {
+	n := 0
+	for _, field := range []string{t.ApiKey, t.ApiKeyFile, t.ApiKeyCommand} {
+		if field != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// LoadTenants reads a JSON file listing the tenants to sync.
+// Tenants without an API key of their own load it from apiKeyFile, with the tenant name inserted before the
+// extension, or from apiKeyCommand. Either may be empty.
+func LoadTenants(path string, apiKeyFile string, apiKeyCommand string) ([]Tenant, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants file: %w", err)
+	}
+	var tenants []Tenant
+	if err := json.Unmarshal(data, &tenants); err != nil {
+		return nil, fmt.Errorf("failed to parse tenants file: %w", err)
+	}
+	for i := range tenants {
+		if tenants[i].keyFields() > 0 {
+			continue
+		}
+		switch {
+		case apiKeyFile != "":
+			tenants[i].ApiKeyFile = tenantPath(apiKeyFile, tenants[i].Name)
+		case apiKeyCommand != "":
+			tenants[i].ApiKeyCommand = apiKeyCommand
+		}
+	}
+	if err := validateTenants(tenants); err != nil {
+		return nil, err
+	}
+	return tenants, nil
+}
+
+// validateTenants checks that every tenant is complete and has a unique name usable in resource IDs.
+func validateTenants(tenants []Tenant) error {
+	if len(tenants) == 0 {
+		return errors.New("at least one tenant is required")
+	}
+	seen := make(map[string]bool, len(tenants))
+	for i, tenant := range tenants {
+		switch {
+		case tenant.Name == "":
+			return fmt.Errorf("tenant %d: name is required", i)
+		case strings.ContainsAny(tenant.Name, tenantSeparator+":"):
+			return fmt.Errorf("tenant %s: name must not contain %q or %q", tenant.Name, tenantSeparator, ":")
+		case seen[tenant.Name]:
+			return fmt.Errorf("tenant %s: duplicate name", tenant.Name)
+		case tenant.ApiUrl == "" && tenant.CompanyName == "":
+			return fmt.Errorf("tenant %s: api_url or company_name is required", tenant.Name)
+		case tenant.keyFields() == 0:
+			return fmt.Errorf("tenant %s: api_key, api_key_file or api_key_command is required", tenant.Name)
+		case tenant.keyFields() > 1:
+			return fmt.Errorf("tenant %s: only one of api_key, api_key_file and api_key_command may be set", tenant.Name)
+		}
+		seen[tenant.Name] = true
+	}
+	return nil
+}
+
+// tenantPath returns the file path used by a tenant for a file configured once for all tenants, such as
+// the grant expiry store: the tenant name is inserted before the extension.
+func tenantPath(path string, tenant string) string {
+	if tenant == "" {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + tenant + ext
+}
+
+// MultiTenantConnector syncs several Zuper accounts. Every resource is namespaced under a parent tenant
+// resource, and calls are routed to the tenant's connector by the tenant prefix of the resource ID.
+type MultiTenantConnector struct {
+	names   []string
+	tenants map[string]*Connector
+}
+
+// NewMultiTenant returns a connector syncing each of the given tenants, applying opts to every tenant.
+// Files configured by options, such as the audit journal, are kept per tenant.
+func NewMultiTenant(ctx context.Context, tenants []Tenant, opts ...Option) (*MultiTenantConnector, error) {
+	if err := validateTenants(tenants); err != nil {
+		return nil, err
+	}
+	m := &MultiTenantConnector{
+		tenants: make(map[string]*Connector, len(tenants)),
+	}
+	for _, tenant := range tenants {
+		apiKey := tenant.ApiKey
+		tenantOpts := append(append([]Option{}, opts...), WithCompanyName(tenant.CompanyName))
+		if source := tenant.keySource(); source != nil {
+			var err error
+			apiKey, err = source(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load API key of tenant %s: %w", tenant.Name, err)
+			}
+			tenantOpts = append(tenantOpts, WithAPIKeySource(source))
+		}
+		c, err := newConnector(ctx, tenant.Name, tenant.ApiUrl, apiKey, tenantOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create connector for tenant %s: %w", tenant.Name, err)
+		}
+		m.names = append(m.names, tenant.Name)
+		m.tenants[tenant.Name] = c
+	}
+	return m, nil
+}
+
+// ResourceSyncers returns the tenant resource syncer and, for each resource type, a syncer routing to the
+// matching builder of every tenant.
+func (m *MultiTenantConnector) ResourceSyncers(ctx context.Context) []connectorbuilder.ResourceSyncer {
+	var resourceTypes []*v2.ResourceType
+	byType := make(map[string]map[string]connectorbuilder.ResourceSyncer)
+	for _, name := range m.names {
+		for _, syncer := range m.tenants[name].ResourceSyncers(ctx) {
+			rt := syncer.ResourceType(ctx)
+			if byType[rt.Id] == nil {
+				resourceTypes = append(resourceTypes, rt)
+				byType[rt.Id] = make(map[string]connectorbuilder.ResourceSyncer, len(m.names))
+			}
+			byType[rt.Id][name] = syncer
+		}
+	}
+
+	var childTypes []string
+	syncers := make([]connectorbuilder.ResourceSyncer, 0, len(resourceTypes)+1)
+	for _, rt := range resourceTypes {
+		// Customer portal users are listed under their customer rather than the tenant.
+		if rt.Id != customerPortalUserResourceType.Id {
+			childTypes = append(childTypes, rt.Id)
+		}
+		syncers = append(syncers, newTenantRouter(rt, m.names, byType[rt.Id]))
+	}
+	return append([]connectorbuilder.ResourceSyncer{newTenantBuilder(m.names, childTypes)}, syncers...)
+}
+
+// Asset fetches a profile picture through the tenant hosting it, falling back to the first tenant.
+func (m *MultiTenantConnector) Asset(ctx context.Context, asset *v2.AssetRef) (string, io.ReadCloser, error) {
+	c := m.tenants[m.names[0]]
+	if pictureURL, err := url.Parse(asset.GetId()); err == nil {
+		for _, name := range m.names {
+			if m.tenants[name].client.IsAPIHost(pictureURL) {
+				c = m.tenants[name]
+				break
+			}
+		}
+	}
+	return c.Asset(ctx, asset)
+}
+
+// Metadata returns metadata about the connector. Account creation additionally asks for the tenant.
+func (m *MultiTenantConnector) Metadata(ctx context.Context) (*v2.ConnectorMetadata, error) {
+	md, err := m.tenants[m.names[0]].Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	md.AccountCreationSchema.FieldMap[tenantProfileField] = &v2.ConnectorAccountCreationSchema_Field{
+		DisplayName: "Tenant",
+		Required:    len(m.names) > 1,
+		Description: fmt.Sprintf("Name of the Zuper tenant the user is created in: %s.", strings.Join(m.names, ", ")),
+		Field: &v2.ConnectorAccountCreationSchema_Field_StringField{
+			StringField: &v2.ConnectorAccountCreationSchema_StringField{},
+		},
+		Placeholder: m.names[0],
+		Order:       9,
+	}
+	return md, nil
+}
+
+// Validate validates every tenant.
+func (m *MultiTenantConnector) Validate(ctx context.Context) (annotations.Annotations, error) {
+	var annos annotations.Annotations
+	for _, name := range m.names {
+		tenantAnnos, err := m.tenants[name].Validate(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to validate tenant %s: %w", name, err)
+		}
+		annos = append(annos, tenantAnnos...)
+	}
+	return annos, nil
+}
+
+// tenantBuilder lists the configured tenants as the parents of all other resources.
+type tenantBuilder struct {
+	resourceType *v2.ResourceType
+	names        []string
+	childTypes   []string
+}
+
+func (t *tenantBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return t.resourceType
+}
+
+// List returns a resource for each tenant.
+func (t *tenantBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentResourceID != nil {
+		return nil, "", nil, nil
+	}
+	var options []resource.ResourceOption
+	for _, childType := range t.childTypes {
+		options = append(options, resource.WithAnnotation(&v2.ChildResourceType{ResourceTypeId: childType}))
+	}
+	resources := make([]*v2.Resource, 0, len(t.names))
+	for _, name := range t.names {
+		tenantResource, err := resource.NewResource(name, t.resourceType, name, options...)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("failed to create tenant resource: %w", err)
+		}
+		resources = append(resources, tenantResource)
+	}
+	return resources, "", nil, nil
+}
+
+// Entitlements returns no entitlements for tenants.
+func (t *tenantBuilder) Entitlements(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+// Grants returns no grants for tenants.
+func (t *tenantBuilder) Grants(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+// newTenantBuilder creates a new instance of tenantBuilder.
+func newTenantBuilder(names []string, childTypes []string) *tenantBuilder {
+	return &tenantBuilder{
+		resourceType: tenantResourceType,
+		names:        names,
+		childTypes:   childTypes,
+	}
+}
+
+// tenantRouter syncs one resource type across tenants. It strips the tenant prefix from the resource IDs
+// it is given, calls the builder of that tenant, and prefixes the IDs of everything the builder returns.
+type tenantRouter struct {
+	resourceType *v2.ResourceType
+	names        []string
+	syncers      map[string]connectorbuilder.ResourceSyncer
+}
+
+// tenantProvisioner routes grants and revokes to the builder of the tenant owning the entitlement.
+type tenantProvisioner struct{ *tenantRouter }
+
+// tenantAccountManager routes account creation to the tenant named in the account profile.
+type tenantAccountManager struct{ *tenantRouter }
+
+// tenantDeleter routes deletes to the builder of the tenant owning the resource.
+type tenantDeleter struct{ *tenantRouter }
+
+// The combinations below embed the router first: its methods win over the ones the capabilities embed, so each
+// combination stays a syncer.
+
+// tenantProvisionerAccountManagerDeleter routes grants, revokes, account creation and deletes.
+type tenantProvisionerAccountManagerDeleter struct {
+	*tenantRouter
+	tenantProvisioner
+	tenantAccountManager
+	tenantDeleter
+}
+
+// tenantProvisionerAccountManager routes grants, revokes and account creation.
+type tenantProvisionerAccountManager struct {
+	*tenantRouter
+	tenantProvisioner
+	tenantAccountManager
+}
+
+// tenantProvisionerDeleter routes grants, revokes and deletes.
+type tenantProvisionerDeleter struct {
+	*tenantRouter
+	tenantProvisioner
+	tenantDeleter
+}
+
+// tenantAccountManagerDeleter routes account creation and deletes.
+type tenantAccountManagerDeleter struct {
+	*tenantRouter
+	tenantAccountManager
+	tenantDeleter
+}
+
+// newTenantRouter returns a syncer routing to the builders of each tenant. Each provisioning capability of the
+// builders is checked on its own, and the router exposes exactly the ones they have.
+func newTenantRouter(rt *v2.ResourceType, names []string, syncers map[string]connectorbuilder.ResourceSyncer) connectorbuilder.ResourceSyncer {
+	router := &tenantRouter{resourceType: rt, names: names, syncers: syncers}
+	builder := syncers[names[0]]
+	_, provisions := builder.(connectorbuilder.ResourceProvisionerV2)
+	_, managesAccounts := builder.(connectorbuilder.AccountManager)
+	_, deletes := builder.(connectorbuilder.ResourceDeleter)
+
+	provisioner := tenantProvisioner{router}
+	accountManager := tenantAccountManager{router}
+	deleter := tenantDeleter{router}
+	switch {
+	case provisions && managesAccounts && deletes:
+		return &tenantProvisionerAccountManagerDeleter{router, provisioner, accountManager, deleter}
+	case provisions && managesAccounts:
+		return &tenantProvisionerAccountManager{router, provisioner, accountManager}
+	case provisions && deletes:
+		return &tenantProvisionerDeleter{router, provisioner, deleter}
+	case managesAccounts && deletes:
+		return &tenantAccountManagerDeleter{router, accountManager, deleter}
+	case provisions:
+		return &provisioner
+	case managesAccounts:
+		return &accountManager
+	case deletes:
+		return &deleter
+	default:
+		return router
+	}
+}
+
+func (r *tenantRouter) ResourceType(ctx context.Context) *v2.ResourceType {
+	return r.resourceType
+}
+
+// syncer returns the builder of the named tenant.
+func (r *tenantRouter) syncer(tenant string) (connectorbuilder.ResourceSyncer, error) {
+	syncer, ok := r.syncers[tenant]
+	if !ok {
+		return nil, fmt.Errorf("unknown tenant %q", tenant)
+	}
+	return syncer, nil
+}
+
+// List lists the resources of the tenant or parent resource. Resources are only listed under a parent.
+func (r *tenantRouter) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentResourceID == nil {
+		return nil, "", nil, nil
+	}
+	tenant, parentID, err := splitTenantResourceID(parentResourceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	syncer, err := r.syncer(tenant)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	resources, nextToken, annos, err := syncer.List(ctx, parentID, pToken)
+	if err != nil {
+		return nil, "", annos, err
+	}
+	for i, res := range resources {
+		resources[i] = tenantResource(tenant, res)
+	}
+	return resources, nextToken, annos, nil
+}
+
+// Entitlements returns the entitlements of a resource from its tenant.
+func (r *tenantRouter) Entitlements(ctx context.Context, res *v2.Resource, pToken *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	tenant, inner, err := untenantResource(res)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	syncer, err := r.syncer(tenant)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	entitlements, nextToken, annos, err := syncer.Entitlements(ctx, inner, pToken)
+	if err != nil {
+		return nil, "", annos, err
+	}
+	for i, ent := range entitlements {
+		entitlements[i] = tenantEntitlement(tenant, ent)
+	}
+	return entitlements, nextToken, annos, nil
+}
+
+// Grants returns the grants of a resource from its tenant.
+func (r *tenantRouter) Grants(ctx context.Context, res *v2.Resource, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	tenant, inner, err := untenantResource(res)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	syncer, err := r.syncer(tenant)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	grants, nextToken, annos, err := syncer.Grants(ctx, inner, pToken)
+	if err != nil {
+		return nil, "", annos, err
+	}
+	for i, g := range grants {
+		grants[i] = tenantGrant(tenant, g)
+	}
+	return grants, nextToken, annos, nil
+}
+
+// Grant grants an entitlement in the tenant owning it. The principal must belong to the same tenant.
+func (p *tenantProvisioner) Grant(ctx context.Context, principal *v2.Resource, ent *v2.Entitlement) ([]*v2.Grant, annotations.Annotations, error) {
+	tenant, innerEnt, err := untenantEntitlement(ent)
+	if err != nil {
+		return nil, nil, err
+	}
+	principalTenant, innerPrincipal, err := untenantResource(principal)
+	if err != nil {
+		return nil, nil, err
+	}
+	if principalTenant != tenant {
+		return nil, nil, fmt.Errorf("cannot grant an entitlement of tenant %s to a principal of tenant %s", tenant, principalTenant)
+	}
+	syncer, err := p.syncer(tenant)
+	if err != nil {
+		return nil, nil, err
+	}
+	grants, annos, err := syncer.(connectorbuilder.ResourceProvisionerV2).Grant(ctx, innerPrincipal, innerEnt)
+	if err != nil {
+		return nil, annos, err
+	}
+	for i, g := range grants {
+		grants[i] = tenantGrant(tenant, g)
+	}
+	return grants, annos, nil
+}
+
+// Revoke revokes a grant in the tenant owning its entitlement. The principal must belong to the same tenant.
+func (p *tenantProvisioner) Revoke(ctx context.Context, g *v2.Grant) (annotations.Annotations, error) {
+	tenant, innerEnt, err := untenantEntitlement(g.GetEntitlement())
+	if err != nil {
+		return nil, err
+	}
+	principalTenant, innerPrincipal, err := untenantResource(g.GetPrincipal())
+	if err != nil {
+		return nil, err
+	}
+	if principalTenant != tenant {
+		return nil, fmt.Errorf("cannot revoke an entitlement of tenant %s from a principal of tenant %s", tenant, principalTenant)
+	}
+	syncer, err := p.syncer(tenant)
+	if err != nil {
+		return nil, err
+	}
+	inner := proto.Clone(g).(*v2.Grant)
+	inner.Entitlement = innerEnt
+	inner.Principal = innerPrincipal
+	inner.Id = grantpkg.NewGrantID(innerPrincipal, innerEnt)
+	return syncer.(connectorbuilder.ResourceProvisionerV2).Revoke(ctx, inner)
+}
+
+// CreateAccountCapabilityDetails returns the account provisioning capabilities shared by all tenants.
+func (a *tenantAccountManager) CreateAccountCapabilityDetails(ctx context.Context) (*v2.CredentialDetailsAccountProvisioning, annotations.Annotations, error) {
+	return a.syncers[a.names[0]].(connectorbuilder.AccountManager).CreateAccountCapabilityDetails(ctx)
+}
+
+// CreateAccount creates the account in the tenant named by the tenant profile field, which may be omitted
+// when only one tenant is configured.
+func (a *tenantAccountManager) CreateAccount(
+	ctx context.Context,
+	accountInfo *v2.AccountInfo,
+	credentialOptions *v2.CredentialOptions,
+) (
+	connectorbuilder.CreateAccountResponse,
+	[]*v2.PlaintextData,
+	annotations.Annotations,
+	error,
+) {
+	tenant := accountInfo.GetProfile().GetFields()[tenantProfileField].GetStringValue()
+	if tenant == "" {
+		if len(a.names) > 1 {
+			return nil, nil, nil, fmt.Errorf("%s is required", tenantProfileField)
+		}
+		tenant = a.names[0]
+	}
+	syncer, err := a.syncer(tenant)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	inner := proto.Clone(accountInfo).(*v2.AccountInfo)
+	if inner.GetProfile() != nil {
+		delete(inner.Profile.Fields, tenantProfileField)
+	}
+	resp, plaintexts, annos, err := syncer.(connectorbuilder.AccountManager).CreateAccount(ctx, inner, credentialOptions)
+	if err != nil {
+		return nil, nil, annos, err
+	}
+	if result, ok := resp.(*v2.CreateAccountResponse_SuccessResult); ok && result.Resource != nil {
+		result.Resource = tenantResource(tenant, result.Resource)
+	}
+	return resp, plaintexts, annos, nil
+}
+
+// Delete deletes a resource in the tenant owning it. A tenant itself cannot be deleted, and the resource must be of
+// the type the router syncs.
+func (d *tenantDeleter) Delete(ctx context.Context, resourceId *v2.ResourceId) (annotations.Annotations, error) {
+	if resourceId.GetResourceType() != d.resourceType.Id {
+		return nil, fmt.Errorf("cannot delete resource %s:%s with the %s builder", resourceId.GetResourceType(), resourceId.GetResource(), d.resourceType.Id)
+	}
+	tenant, inner, err := splitTenantResourceID(resourceId)
+	if err != nil {
+		return nil, err
+	}
+	syncer, err := d.syncer(tenant)
+	if err != nil {
+		return nil, err
+	}
+	return syncer.(connectorbuilder.ResourceDeleter).Delete(ctx, inner)
+}
+
+// tenantResourceID returns the ID of a resource of tenant. A nil id refers to the tenant itself.
+func tenantResourceID(tenant string, id *v2.ResourceId) *v2.ResourceId {
+	if id == nil {
+		return &v2.ResourceId{ResourceType: tenantResourceType.Id, Resource: tenant}
+	}
+	return &v2.ResourceId{ResourceType: id.ResourceType, Resource: tenant + tenantSeparator + id.Resource}
+}
+
+// splitTenantResourceID returns the tenant of a resource ID and the ID known to that tenant. The inner ID
+// is nil for the tenant resource itself.
+func splitTenantResourceID(id *v2.ResourceId) (string, *v2.ResourceId, error) {
+	if id.GetResourceType() == tenantResourceType.Id {
+		return id.GetResource(), nil, nil
+	}
+	tenant, resourceID, ok := strings.Cut(id.GetResource(), tenantSeparator)
+	if !ok || tenant == "" {
+		return "", nil, fmt.Errorf("resource %s:%s does not belong to a tenant", id.GetResourceType(), id.GetResource())
+	}
+	return tenant, &v2.ResourceId{ResourceType: id.GetResourceType(), Resource: resourceID}, nil
+}
+
+// tenantEntitlementID returns the ID of an entitlement of tenant, given the ID known to that tenant.
+// Entitlement IDs have the form type:resource:slug, so the tenant prefix goes in front of the resource.
+func tenantEntitlementID(tenant string, id string) string {
+	resourceType, rest, ok := strings.Cut(id, ":")
+	if !ok {
+		return id
+	}
+	return resourceType + ":" + tenant + tenantSeparator + rest
+}
+
+// untenantEntitlementID reverses tenantEntitlementID.
+func untenantEntitlementID(id string) string {
+	resourceType, rest, ok := strings.Cut(id, ":")
+	if !ok {
+		return id
+	}
+	if _, inner, ok := strings.Cut(rest, tenantSeparator); ok {
+		return resourceType + ":" + inner
+	}
+	return id
+}
+
+// tenantResource returns a copy of a resource of tenant with tenant-scoped IDs, parented to the tenant
+// when the builder did not set a parent.
+func tenantResource(tenant string, res *v2.Resource) *v2.Resource {
+	if res == nil {
+		return nil
+	}
+	out := proto.Clone(res).(*v2.Resource)
+	out.Id = tenantResourceID(tenant, res.GetId())
+	out.ParentResourceId = tenantResourceID(tenant, res.GetParentResourceId())
+	return out
+}
+
+// untenantResource returns the tenant of a resource and a copy of it with the IDs known to that tenant.
+func untenantResource(res *v2.Resource) (string, *v2.Resource, error) {
+	tenant, id, err := splitTenantResourceID(res.GetId())
+	if err != nil {
+		return "", nil, err
+	}
+	out := proto.Clone(res).(*v2.Resource)
+	out.Id = id
+	out.ParentResourceId = nil
+	if parent := res.GetParentResourceId(); parent != nil {
+		_, out.ParentResourceId, err = splitTenantResourceID(parent)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	return tenant, out, nil
+}
+
+// tenantEntitlement returns a copy of an entitlement of tenant with tenant-scoped IDs.
+func tenantEntitlement(tenant string, ent *v2.Entitlement) *v2.Entitlement {
+	if ent == nil {
+		return nil
+	}
+	out := proto.Clone(ent).(*v2.Entitlement)
+	out.Id = tenantEntitlementID(tenant, ent.GetId())
+	out.Resource = tenantResource(tenant, ent.GetResource())
+	return out
+}
+
+// untenantEntitlement returns the tenant of an entitlement and a copy of it with the IDs known to that tenant.
+func untenantEntitlement(ent *v2.Entitlement) (string, *v2.Entitlement, error) {
+	tenant, res, err := untenantResource(ent.GetResource())
+	if err != nil {
+		return "", nil, err
+	}
+	out := proto.Clone(ent).(*v2.Entitlement)
+	out.Id = untenantEntitlementID(ent.GetId())
+	out.Resource = res
+	return tenant, out, nil
+}
+
+// tenantGrant returns a copy of a grant of tenant with tenant-scoped IDs, including the entitlements
+// it expands to.
+func tenantGrant(tenant string, g *v2.Grant) *v2.Grant {
+	out := proto.Clone(g).(*v2.Grant)
+	out.Entitlement = tenantEntitlement(tenant, g.GetEntitlement())
+	out.Principal = tenantResource(tenant, g.GetPrincipal())
+	if out.Entitlement != nil && out.Principal != nil {
+		out.Id = grantpkg.NewGrantID(out.Principal, out.Entitlement)
+	}
+	for i, a := range out.Annotations {
+		expandable := &v2.GrantExpandable{}
+		if !a.MessageIs(expandable) || a.UnmarshalTo(expandable) != nil {
+			continue
+		}
+		for j, id := range expandable.EntitlementIds {
+			expandable.EntitlementIds[j] = tenantEntitlementID(tenant, id)
+		}
+		if updated, err := anypb.New(expandable); err == nil {
+			out.Annotations[i] = updated
+		}
+	}
+	return out
+}
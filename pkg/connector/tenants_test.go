@@ -0,0 +1,218 @@
+package connector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	grantpkg "github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/conductorone/baton-zuper/pkg/client"
+	"github.com/conductorone/baton-zuper/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestTeamRouter returns a team router over one mock client per tenant.
+func newTestTeamRouter(clients map[string]*test.MockClient) connectorbuilder.ResourceSyncer {
+	names := []string{"us", "eu"}
+	syncers := make(map[string]connectorbuilder.ResourceSyncer, len(names))
+	for _, name := range names {
+		syncers[name] = &teamBuilder{resourceType: teamResourceType, client: clients[name]}
+	}
+	return newTenantRouter(teamResourceType, names, syncers)
+}
+
+// TestTenantRouter tests that resources are namespaced under their tenant and calls reach the tenant's client.
+func TestTenantRouter(t *testing.T) {
+	ctx := context.Background()
+	var assignedTeam, assignedUser string
+	clients := map[string]*test.MockClient{
+		"us": {
+			GetTeamsFunc: func(ctx context.Context, options client.PageOptions) ([]*client.Team, string, annotations.Annotations, error) {
+				return []*client.Team{{TeamUID: "team-1", TeamName: "Team One"}}, "", nil, nil
+			},
+			GetTeamUsersFunc: func(ctx context.Context, teamID string) ([]*client.ZuperUser, string, annotations.Annotations, error) {
+				return []*client.ZuperUser{{UserUID: "user-1"}}, "", nil, nil
+			},
+		},
+		"eu": {
+			AssignUserToTeamFunc: func(ctx context.Context, teamUID, userUID string) (*client.AssignUserToTeamResponse, annotations.Annotations, error) {
+				assignedTeam, assignedUser = teamUID, userUID
+				return &client.AssignUserToTeamResponse{Message: "User assigned to team"}, nil, nil
+			},
+		},
+	}
+	router := newTestTeamRouter(clients)
+
+	resources, _, _, err := router.List(ctx, nil, &pagination.Token{})
+	require.NoError(t, err)
+	assert.Empty(t, resources, "resources are only listed under a tenant")
+
+	resources, _, _, err = router.List(ctx, &v2.ResourceId{ResourceType: tenantResourceType.Id, Resource: "us"}, &pagination.Token{})
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	assert.Equal(t, "us/team-1", resources[0].Id.Resource)
+	assert.Equal(t, &v2.ResourceId{ResourceType: tenantResourceType.Id, Resource: "us"}, resources[0].ParentResourceId)
+
+	grants, _, _, err := router.Grants(ctx, resources[0], &pagination.Token{})
+	require.NoError(t, err)
+	require.Len(t, grants, 1)
+	assert.Equal(t, "team:us/team-1:member", grants[0].Entitlement.Id)
+	assert.Equal(t, "us/user-1", grants[0].Principal.Id.Resource)
+	assert.Equal(t, "team:us/team-1:member:user:us/user-1", grants[0].Id)
+
+	provisioner, ok := router.(connectorbuilder.ResourceProvisionerV2)
+	require.True(t, ok)
+	team := &v2.Resource{Id: &v2.ResourceId{ResourceType: teamResourceType.Id, Resource: "eu/team-2"}}
+	ent := &v2.Entitlement{Id: "team:eu/team-2:member", Resource: team, Slug: entitlementTeamMember}
+	user := &v2.Resource{Id: &v2.ResourceId{ResourceType: userResourceType.Id, Resource: "eu/user-2"}}
+	grants, _, err = provisioner.Grant(ctx, user, ent)
+	require.NoError(t, err)
+	assert.Equal(t, "team-2", assignedTeam)
+	assert.Equal(t, "user-2", assignedUser)
+	require.Len(t, grants, 1)
+	assert.Equal(t, "eu/user-2", grants[0].Principal.Id.Resource)
+
+	otherUser := &v2.Resource{Id: &v2.ResourceId{ResourceType: userResourceType.Id, Resource: "us/user-1"}}
+	_, _, err = provisioner.Grant(ctx, otherUser, ent)
+	assert.ErrorContains(t, err, "cannot grant an entitlement of tenant eu to a principal of tenant us")
+	_, err = provisioner.Revoke(ctx, &v2.Grant{Entitlement: ent, Principal: otherUser})
+	assert.ErrorContains(t, err, "cannot revoke an entitlement of tenant eu from a principal of tenant us")
+
+	_, _, _, err = router.Grants(ctx, &v2.Resource{Id: &v2.ResourceId{ResourceType: teamResourceType.Id, Resource: "team-1"}}, &pagination.Token{})
+	assert.ErrorContains(t, err, "does not belong to a tenant")
+}
+
+// deletableTeamBuilder is a team builder that can also delete teams.
+type deletableTeamBuilder struct {
+	*teamBuilder
+	deleted string
+}
+
+func (b *deletableTeamBuilder) Delete(ctx context.Context, resourceId *v2.ResourceId) (annotations.Annotations, error) {
+	b.deleted = resourceId.Resource
+	return nil, nil
+}
+
+// TestTenantRouter_Capabilities tests that the router exposes every provisioning capability of the builders.
+func TestTenantRouter_Capabilities(t *testing.T) {
+	ctx := context.Background()
+	names := []string{"us", "eu"}
+	builders := make(map[string]*deletableTeamBuilder, len(names))
+	syncers := make(map[string]connectorbuilder.ResourceSyncer, len(names))
+	for _, name := range names {
+		builders[name] = &deletableTeamBuilder{teamBuilder: &teamBuilder{resourceType: teamResourceType, client: &test.MockClient{}}}
+		syncers[name] = builders[name]
+	}
+	router := newTenantRouter(teamResourceType, names, syncers)
+
+	_, ok := router.(connectorbuilder.ResourceProvisionerV2)
+	assert.True(t, ok, "the router grants")
+	_, ok = router.(connectorbuilder.AccountManager)
+	assert.False(t, ok, "the router does not create accounts")
+	deleter, ok := router.(connectorbuilder.ResourceDeleter)
+	require.True(t, ok, "the router deletes")
+
+	_, err := deleter.Delete(ctx, &v2.ResourceId{ResourceType: teamResourceType.Id, Resource: "eu/team-2"})
+	require.NoError(t, err)
+	assert.Equal(t, "team-2", builders["eu"].deleted)
+	assert.Empty(t, builders["us"].deleted)
+	assert.Equal(t, teamResourceType, router.ResourceType(ctx))
+
+	builders["eu"].deleted = ""
+	_, err = deleter.Delete(ctx, &v2.ResourceId{ResourceType: tenantResourceType.Id, Resource: "eu"})
+	assert.ErrorContains(t, err, "cannot delete resource tenant:eu with the team builder")
+	_, err = deleter.Delete(ctx, &v2.ResourceId{ResourceType: userResourceType.Id, Resource: "eu/user-2"})
+	assert.Error(t, err)
+	assert.Empty(t, builders["eu"].deleted)
+}
+
+// TestTenantGrant_Expandable tests that the entitlements a grant expands to are namespaced under the tenant.
+func TestTenantGrant_Expandable(t *testing.T) {
+	role := &v2.Resource{Id: &v2.ResourceId{ResourceType: accessRoleResourceType.Id, Resource: "role-1"}}
+	g := grantpkg.NewGrant(role, "jobs:read", &v2.ResourceId{ResourceType: permissionResourceType.Id, Resource: "jobs"},
+		grantpkg.WithAnnotation(&v2.GrantExpandable{EntitlementIds: []string{"access-role:role-1:assigned"}}),
+	)
+
+	out := tenantGrant("eu", g)
+	annos := annotations.Annotations(out.Annotations)
+	expandable := &v2.GrantExpandable{}
+	ok, err := annos.Pick(expandable)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, []string{"access-role:eu/role-1:assigned"}, expandable.EntitlementIds)
+	assert.Equal(t, "access-role:role-1:assigned", untenantEntitlementID(expandable.EntitlementIds[0]))
+	assert.Equal(t, "role-1", g.Entitlement.Resource.Id.Resource, "the builder's grant is not modified")
+}
+
+// TestLoadTenants tests reading and validating the tenants file.
+func TestLoadTenants(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "tenants.json")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	tenants, err := LoadTenants(write(`[
+		{"name": "us", "api_url": "https://us.zuperpro.com", "api_key": "key-us"},
+		{"name": "eu", "api_url": "https://eu.zuperpro.com", "api_key_file": "/run/secrets/zuper-eu"},
+		{"name": "au", "company_name": "acme", "api_key_command": "vault read -field=key zuper/au"}
+	]`), "", "")
+	require.NoError(t, err)
+	assert.Equal(t, []Tenant{
+		{Name: "us", ApiUrl: "https://us.zuperpro.com", ApiKey: "key-us"},
+		{Name: "eu", ApiUrl: "https://eu.zuperpro.com", ApiKeyFile: "/run/secrets/zuper-eu"},
+		{Name: "au", CompanyName: "acme", ApiKeyCommand: "vault read -field=key zuper/au"},
+	}, tenants)
+	assert.Nil(t, tenants[0].keySource())
+	assert.NotNil(t, tenants[1].keySource())
+	assert.NotNil(t, tenants[2].keySource())
+
+	invalid := map[string]string{
+		"empty":           `[]`,
+		"missing name":    `[{"api_url": "https://us.zuperpro.com", "api_key": "key"}]`,
+		"separator":       `[{"name": "us/east", "api_url": "https://us.zuperpro.com", "api_key": "key"}]`,
+		"duplicate":       `[{"name": "us", "api_url": "https://a", "api_key": "a"}, {"name": "us", "api_url": "https://b", "api_key": "b"}]`,
+		"missing api key": `[{"name": "us", "api_url": "https://us.zuperpro.com"}]`,
+		"two api keys":    `[{"name": "us", "api_url": "https://us.zuperpro.com", "api_key": "key", "api_key_file": "/run/secrets/zuper"}]`,
+	}
+	for name, content := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadTenants(write(content), "", "")
+			assert.Error(t, err)
+		})
+	}
+
+	t.Run("default key file", func(t *testing.T) {
+		keyFile := filepath.Join(dir, "key.txt")
+		require.NoError(t, os.WriteFile(tenantPath(keyFile, "us"), []byte("key-us\n"), 0o600))
+		tenants, err := LoadTenants(write(`[
+			{"name": "us", "api_url": "https://us.zuperpro.com"},
+			{"name": "eu", "api_url": "https://eu.zuperpro.com", "api_key": "key-eu"}
+		]`), keyFile, "")
+		require.NoError(t, err)
+		assert.Equal(t, tenantPath(keyFile, "us"), tenants[0].ApiKeyFile)
+		assert.Empty(t, tenants[1].ApiKeyFile, "a tenant's own key wins")
+		key, err := tenants[0].keySource()(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "key-us", key)
+	})
+
+	t.Run("default key command", func(t *testing.T) {
+		tenants, err := LoadTenants(write(`[{"name": "us", "api_url": "https://us.zuperpro.com"}]`), "", "echo key-$ZUPER_TENANT")
+		require.NoError(t, err)
+		key, err := tenants[0].keySource()(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "key-us", key)
+	})
+
+	assert.Equal(t, "/var/lib/zuper/expiries.us.json", tenantPath("/var/lib/zuper/expiries.json", "us"))
+	assert.Equal(t, "journal.eu", tenantPath("journal", "eu"))
+	assert.Equal(t, "journal.jsonl", tenantPath("journal.jsonl", ""))
+}
@@ -0,0 +1,157 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-zuper/pkg/client"
+)
+
+// Entitlement value representing territory membership.
+const (
+	entitlementTerritoryMember = "member"
+)
+
+type territoryClientInterface interface {
+	GetTerritories(ctx context.Context, options client.PageOptions) ([]*client.Territory, string, annotations.Annotations, error)
+	GetTerritoryUsers(ctx context.Context, territoryUID string) ([]*client.ZuperUser, annotations.Annotations, error)
+	AssignUserToTerritory(ctx context.Context, territoryUID, userUID string) (*client.AssignUserToTerritoryResponse, annotations.Annotations, error)
+	UnassignUserFromTerritory(ctx context.Context, territoryUID, userUID string) (*client.AssignUserToTerritoryResponse, annotations.Annotations, error)
+}
+
+// territoryBuilder is a builder for service territory resources.
+type territoryBuilder struct {
+	resourceType *v2.ResourceType
+	client       territoryClientInterface
+	members      *membership
+}
+
+func (t *territoryBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return territoryResourceType
+}
+
+// parseIntoTerritoryResource converts a Territory into a Baton v2.Resource.
+func parseIntoTerritoryResource(territory *client.Territory) (*v2.Resource, error) {
+	profile := map[string]interface{}{
+		"territory_name":        territory.TerritoryName,
+		"territory_description": territory.TerritoryDescription,
+		"territory_color":       territory.TerritoryColor,
+		"user_count":            territory.UserCount,
+		"is_active":             territory.IsActive,
+		"created_at":            territory.CreatedAt,
+		"updated_at":            territory.UpdatedAt,
+	}
+	return resource.NewGroupResource(
+		territory.TerritoryName,
+		territoryResourceType,
+		territory.TerritoryUID,
+		[]resource.GroupTraitOption{
+			resource.WithGroupProfile(profile),
+		},
+	)
+}
+
+// List returns the territories as Baton resources, with pagination.
+func (t *territoryBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	return listResources(ctx, territoryResourceType, pToken, t.client.GetTerritories, parseIntoTerritoryResource)
+}
+
+// Entitlements returns a "member" entitlement for each territory, grantable to users.
+func (t *territoryBuilder) Entitlements(ctx context.Context, territoryResource *v2.Resource, pToken *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return t.members.entitlements(
+		territoryResource,
+		fmt.Sprintf("Member of %s", territoryResource.DisplayName),
+		fmt.Sprintf("Member of territory %s", territoryResource.DisplayName),
+	)
+}
+
+// Grants returns grants for the "member" entitlement for each user assigned to the territory.
+func (t *territoryBuilder) Grants(ctx context.Context, territoryResource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	annos := annotations.Annotations{}
+	territoryID := territoryResource.Id.Resource
+	users, _, err := t.client.GetTerritoryUsers(ctx, territoryID)
+	if err != nil {
+		return nil, "", annos, fmt.Errorf("failed to get territory users for %s: %w", territoryID, err)
+	}
+	var grants []*v2.Grant
+	for _, user := range users {
+		grantObj := grant.NewGrant(
+			territoryResource,
+			entitlementTerritoryMember,
+			makeUserSubjectID(user.UserUID),
+			grant.WithGrantMetadata(map[string]interface{}{
+				"territory_id":   territoryID,
+				"territory_name": territoryResource.DisplayName,
+				"user_id":        user.UserUID,
+				"username":       user.Email,
+			}),
+		)
+		grants = append(grants, grantObj)
+	}
+	return grants, "", annos, nil
+}
+
+// isUserInTerritory checks if a user is already assigned to a territory, reading past the HTTP cache so Grant and
+// Revoke decide against the current assignments.
+func (t *territoryBuilder) isUserInTerritory(ctx context.Context, territoryID, userID string) (bool, error) {
+	users, _, err := t.client.GetTerritoryUsers(client.WithoutCache(ctx), territoryID)
+	if err != nil {
+		return false, err
+	}
+	for _, user := range users {
+		if user.UserUID == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// assignUser adds a user to a territory.
+func (t *territoryBuilder) assignUser(ctx context.Context, territoryID, userID string) (map[string]interface{}, annotations.Annotations, error) {
+	resp, annos, err := t.client.AssignUserToTerritory(ctx, territoryID, userID)
+	if err != nil {
+		return nil, annos, err
+	}
+	return map[string]interface{}{
+		"territory_id": territoryID,
+		"user_id":      userID,
+		"message":      resp.Message,
+	}, annos, nil
+}
+
+// unassignUser removes a user from a territory.
+func (t *territoryBuilder) unassignUser(ctx context.Context, territoryID, userID string) (annotations.Annotations, error) {
+	_, annos, err := t.client.UnassignUserFromTerritory(ctx, territoryID, userID)
+	return annos, err
+}
+
+// Grant assigns a user to a territory. Used for territory membership provisioning.
+func (t *territoryBuilder) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) ([]*v2.Grant, annotations.Annotations, error) {
+	return t.members.grant(ctx, principal, entitlement, t.assignUser)
+}
+
+// Revoke removes a user from a territory. Used for territory membership deprovisioning.
+func (t *territoryBuilder) Revoke(ctx context.Context, g *v2.Grant) (annotations.Annotations, error) {
+	return t.members.revoke(ctx, g)
+}
+
+// newTerritoryBuilder creates a new instance of territoryBuilder.
+func newTerritoryBuilder(client territoryClientInterface) *territoryBuilder {
+	t := &territoryBuilder{
+		resourceType: territoryResourceType,
+		client:       client,
+	}
+	t.members = &membership{
+		slug:          entitlementTerritoryMember,
+		name:          "territory membership",
+		principalType: userResourceType,
+		has:           t.isUserInTerritory,
+		remove:        t.unassignUser,
+	}
+	return t
+}
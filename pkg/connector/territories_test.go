@@ -0,0 +1,94 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-zuper/pkg/client"
+	"github.com/conductorone/baton-zuper/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTerritoryBuilder_Grants tests that territory assignments are synced as member grants.
+func TestTerritoryBuilder_Grants(t *testing.T) {
+	mockCli := &test.MockClient{
+		GetTerritoryUsersFunc: func(ctx context.Context, territoryUID string) ([]*client.ZuperUser, annotations.Annotations, error) {
+			return []*client.ZuperUser{{UserUID: "user-1", Email: "one@example.com"}, {UserUID: "user-2"}}, nil, nil
+		},
+	}
+	builder := newTerritoryBuilder(mockCli)
+	territoryRes, err := parseIntoTerritoryResource(&client.Territory{TerritoryUID: "north", TerritoryName: "North"})
+	require.NoError(t, err)
+
+	grants, _, _, err := builder.Grants(context.Background(), territoryRes, &pagination.Token{})
+	require.NoError(t, err)
+	require.Len(t, grants, 2)
+	assert.Equal(t, "user-1", grants[0].Principal.Id.Resource)
+	assert.Equal(t, "territory:north:member", grants[0].Entitlement.Id)
+}
+
+// TestTerritoryBuilder_GrantRevoke tests provisioning and deprovisioning of territory membership.
+func TestTerritoryBuilder_GrantRevoke(t *testing.T) {
+	members := map[string]bool{"user-1": true}
+	mockCli := &test.MockClient{
+		GetTerritoryUsersFunc: func(ctx context.Context, territoryUID string) ([]*client.ZuperUser, annotations.Annotations, error) {
+			var users []*client.ZuperUser
+			for id := range members {
+				users = append(users, &client.ZuperUser{UserUID: id})
+			}
+			return users, nil, nil
+		},
+		AssignTerritoryFunc: func(ctx context.Context, territoryUID, userUID string) (*client.AssignUserToTerritoryResponse, annotations.Annotations, error) {
+			if userUID == "broken" {
+				return nil, nil, errors.New("mock error")
+			}
+			members[userUID] = true
+			return &client.AssignUserToTerritoryResponse{Message: "assigned"}, nil, nil
+		},
+		UnassignTerritoryFunc: func(ctx context.Context, territoryUID, userUID string) (*client.AssignUserToTerritoryResponse, annotations.Annotations, error) {
+			delete(members, userUID)
+			return &client.AssignUserToTerritoryResponse{Message: "unassigned"}, nil, nil
+		},
+	}
+	builder := newTerritoryBuilder(mockCli)
+	territoryRes := &v2.Resource{Id: &v2.ResourceId{ResourceType: territoryResourceType.Id, Resource: "north"}}
+	ent := &v2.Entitlement{Resource: territoryRes}
+	user2 := &v2.Resource{Id: makeUserSubjectID("user-2")}
+
+	t.Run("assigns new member", func(t *testing.T) {
+		grants, annos, err := builder.Grant(context.Background(), user2, ent)
+		require.NoError(t, err)
+		assert.Len(t, grants, 1)
+		assert.Empty(t, annos)
+		assert.True(t, members["user-2"])
+	})
+
+	t.Run("already a member", func(t *testing.T) {
+		grants, annos, err := builder.Grant(context.Background(), user2, ent)
+		require.NoError(t, err)
+		assert.Nil(t, grants)
+		assert.True(t, annos.Contains(&v2.GrantAlreadyExists{}))
+	})
+
+	t.Run("removes member", func(t *testing.T) {
+		_, err := builder.Revoke(context.Background(), &v2.Grant{Entitlement: ent, Principal: user2})
+		require.NoError(t, err)
+		assert.False(t, members["user-2"])
+	})
+
+	t.Run("already revoked", func(t *testing.T) {
+		annos, err := builder.Revoke(context.Background(), &v2.Grant{Entitlement: ent, Principal: user2})
+		require.NoError(t, err)
+		assert.True(t, annos.Contains(&v2.GrantAlreadyRevoked{}))
+	})
+
+	t.Run("client error", func(t *testing.T) {
+		_, _, err := builder.Grant(context.Background(), &v2.Resource{Id: makeUserSubjectID("broken")}, ent)
+		assert.Error(t, err)
+	})
+}
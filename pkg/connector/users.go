@@ -2,7 +2,10 @@ package connector
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"iter"
+	"time"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
@@ -11,19 +14,32 @@ import (
 	grantpkg "github.com/conductorone/baton-sdk/pkg/types/grant"
 	"github.com/conductorone/baton-sdk/pkg/types/resource"
 	"github.com/conductorone/baton-zuper/pkg/client"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
 )
 
 // UserClient defines the interface for fetching users with pagination options.
 type UserClient interface {
 	GetUsers(ctx context.Context, options client.PageOptions) ([]*client.ZuperUser, string, annotations.Annotations, error)
+	EachUser(ctx context.Context, options client.PageOptions, fn func(*client.ZuperUser) error) (string, annotations.Annotations, error)
+	AllUsers(ctx context.Context, annos *annotations.Annotations) iter.Seq2[*client.ZuperUser, error]
 	GetUserByID(ctx context.Context, userUID string) (*client.ZuperUser, annotations.Annotations, error)
 	CreateUser(ctx context.Context, user client.UserPayload) (*client.CreateUserResponse, annotations.Annotations, error)
 	UpdateUserAccessRole(ctx context.Context, userUID string, accessRoleUID string) (*client.UpdateUserRoleResponse, annotations.Annotations, error)
+	UpdateUserAccessRoleIfUnchanged(ctx context.Context, observed *client.ZuperUser, accessRoleUID string) (*client.UpdateUserRoleResponse, annotations.Annotations, error)
+	AssignUserToTeam(ctx context.Context, teamUID, userUID string) (*client.AssignUserToTeamResponse, annotations.Annotations, error)
+	UnassignUserFromTeam(ctx context.Context, teamUID, userUID string) (*client.AssignUserToTeamResponse, annotations.Annotations, error)
+	DeactivateUser(ctx context.Context, userUID string) (*client.UpdateUserRoleResponse, annotations.Annotations, error)
 }
 
 type userBuilder struct {
 	resourceType *v2.ResourceType
 	client       UserClient
+	classifier   *accountClassifier
+	// accessRoles resolves access roles that Zuper returned without a UID.
+	accessRoles *accessRoleBuilder
+	// sweep reports whether the expiry sweep runs, deactivating accounts created with an expiry once it passes.
+	sweep bool
 }
 
 // ResourceType returns the resource type for users.
@@ -38,20 +54,19 @@ func (o *userBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId,
 	if err != nil {
 		return nil, "", nil, err
 	}
-	users, nextPageToken, annotation, err := o.client.GetUsers(ctx, client.PageOptions{
+	nextPageToken, annotation, err := o.client.EachUser(ctx, client.PageOptions{
 		PageSize:  pToken.Size,
 		PageToken: pageToken,
-	})
-	if err != nil {
-		return nil, "", nil, err
-	}
-
-	for _, user := range users {
-		userResource, err := parseIntoUserResource(user)
+	}, func(user *client.ZuperUser) error {
+		userResource, err := parseIntoUserResource(user, o.classifier.classify(user))
 		if err != nil {
-			return nil, "", nil, err
+			return err
 		}
 		resources = append(resources, userResource)
+		return nil
+	})
+	if err != nil {
+		return nil, "", nil, err
 	}
 	var outToken string
 	if nextPageToken != "" {
@@ -64,6 +79,47 @@ func (o *userBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId,
 	return resources, outToken, annotation, nil
 }
 
+// deactivateExpiredUsers performs the scheduled deactivation of every active account whose expiry is at or before
+// now, and logs what it deactivated. It returns the errors of the accounts it failed to deactivate.
+func (o *userBuilder) deactivateExpiredUsers(ctx context.Context, now time.Time) error {
+	var expired []*client.ZuperUser
+	for user, err := range o.client.AllUsers(ctx, nil) {
+		if err != nil {
+			return fmt.Errorf("failed to list users for the expired account sweep: %w", err)
+		}
+		if isExpired(user, now) {
+			expired = append(expired, user)
+		}
+	}
+
+	var errs []error
+	for _, user := range expired {
+		if err := o.deactivateExpiredUser(ctx, user); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	ctxzap.Extract(ctx).Info("expired account sweep complete",
+		zap.Int("expired", len(expired)),
+		zap.Int("deactivated", len(expired)-len(errs)),
+		zap.Int("failed", len(errs)),
+	)
+	return errors.Join(errs...)
+}
+
+// deactivateExpiredUser performs the scheduled deactivation of an account whose expiry has passed.
+func (o *userBuilder) deactivateExpiredUser(ctx context.Context, user *client.ZuperUser) error {
+	l := ctxzap.Extract(ctx)
+	_, _, err := o.client.DeactivateUser(ctx, user.UserUID)
+	if err != nil {
+		return fmt.Errorf("failed to deactivate expired user %s: %w", user.UserUID, err)
+	}
+	l.Info("deactivated expired Zuper account",
+		zap.String("user_uid", user.UserUID),
+		zap.String("account_expires_at", user.AccountExpiresAt),
+	)
+	return nil
+}
+
 // Entitlements returns the entitlements for a user resource (none in this implementation).
 func (o *userBuilder) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
 	return nil, "", nil, nil
@@ -82,25 +138,55 @@ func (o *userBuilder) Grants(ctx context.Context, resourceUser *v2.Resource, pTo
 		return nil, "", annos, nil
 	}
 
+	l := ctxzap.Extract(ctx)
+
 	// Grant Role.
 	if user.Role != nil {
-		roleRes := makeRoleSubjectID(user.Role.RoleKey, user)
-		userId := makeUserSubjectID(user.UserUID)
-		grant := grantpkg.NewGrant(roleRes, assignedEntitlement, userId)
-		grants = append(grants, grant)
+		if role, ok := lookupRole(user.Role); ok {
+			roleRes := makeRoleSubjectID(role.RoleKey, user)
+			userId := makeUserSubjectID(user.UserUID)
+			grant := grantpkg.NewGrant(roleRes, assignedEntitlement, userId)
+			grants = append(grants, grant)
+		} else {
+			l.Warn("skipping role grant: Zuper returned a role that matches no known role",
+				zap.String("user_uid", user.UserUID),
+				zap.String("role_uid", user.Role.RoleUID),
+				zap.String("role_key", user.Role.RoleKey),
+				zap.String("role_name", user.Role.RoleName),
+			)
+		}
 	}
 
 	// Grant AccessRole.
 	if user.AccessRole != nil {
-		accessRoleRes := makeAccessRoleSubjectID(user.AccessRole.AccessRoleUID, user)
-		userId := makeUserSubjectID(user.UserUID)
-		grant := grantpkg.NewGrant(accessRoleRes, assignedEntitlement, userId)
-		grants = append(grants, grant)
+		accessRoleUID, ok, err := o.resolveAccessRole(ctx, user.AccessRole)
+		if err != nil {
+			return nil, "", annos, err
+		}
+		if ok {
+			accessRoleRes := makeAccessRoleSubjectID(accessRoleUID, user)
+			userId := makeUserSubjectID(user.UserUID)
+			grant := grantpkg.NewGrant(accessRoleRes, assignedEntitlement, userId)
+			grants = append(grants, grant)
+		} else {
+			l.Warn("skipping access role grant: Zuper returned an access role without a UID that matches no known access role",
+				zap.String("user_uid", user.UserUID),
+				zap.String("access_role_name", user.AccessRole.AccessRoleName),
+			)
+		}
 	}
 
 	return grants, "", annos, nil
 }
 
+// resolveAccessRole returns the UID of a user's access role, resolving it by name when Zuper omitted the UID.
+func (o *userBuilder) resolveAccessRole(ctx context.Context, role *client.AccessRole) (string, bool, error) {
+	if role.AccessRoleUID != "" || o.accessRoles == nil {
+		return role.AccessRoleUID, role.AccessRoleUID != "", nil
+	}
+	return o.accessRoles.resolveUID(ctx, role)
+}
+
 // CreateAccountCapabilityDetails declares support for account provisioning with password.
 func (u *userBuilder) CreateAccountCapabilityDetails(ctx context.Context) (*v2.CredentialDetailsAccountProvisioning, annotations.Annotations, error) {
 	return &v2.CredentialDetailsAccountProvisioning{
@@ -111,7 +197,8 @@ func (u *userBuilder) CreateAccountCapabilityDetails(ctx context.Context) (*v2.C
 	}, nil, nil
 }
 
-// CreateAccount provisions a new Zuper user based on AccountInfo and CredentialOptions.
+// CreateAccount provisions a new Zuper user based on AccountInfo and CredentialOptions, then assigns the optional
+// access role and teams. The steps run as a saga: if any of them fails, the completed ones are undone.
 func (u *userBuilder) CreateAccount(
 	ctx context.Context,
 	accountInfo *v2.AccountInfo,
@@ -135,6 +222,49 @@ func (u *userBuilder) CreateAccount(
 		}
 	}
 
+	class := accountClassEmployee
+	if val, ok := profile["account_class"].(string); ok && val != "" {
+		switch accountClass(val) {
+		case accountClassEmployee, accountClassContractor:
+			class = accountClass(val)
+		default:
+			return nil, nil, nil, fmt.Errorf("invalid account_class %q: expected %s or %s", val, accountClassEmployee, accountClassContractor)
+		}
+	}
+
+	var accountExpiresAt string
+	if val, ok := profile["expires_at"].(string); ok && val != "" {
+		if class != accountClassContractor {
+			return nil, nil, nil, fmt.Errorf("expires_at is only supported for %s accounts", accountClassContractor)
+		}
+		if !u.sweep {
+			return nil, nil, nil, errors.New("expires_at requires the expiry-sweep option, which deactivates the account once it expires")
+		}
+		expiresAt, err := parseExpiryDate(val)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if !expiresAt.After(time.Now()) {
+			return nil, nil, nil, fmt.Errorf("expires_at must be in the future")
+		}
+		accountExpiresAt = expiresAt.Format(time.RFC3339)
+	}
+
+	var accessRoleUID string
+	if val, ok := profile["access_role"].(string); ok {
+		accessRoleUID = val
+	}
+	var teamUIDs []string
+	if val, ok := profile["teams"].([]interface{}); ok {
+		for _, team := range val {
+			teamUID, ok := team.(string)
+			if !ok || teamUID == "" {
+				return nil, nil, nil, fmt.Errorf("teams must be a list of team IDs")
+			}
+			teamUIDs = append(teamUIDs, teamUID)
+		}
+	}
+
 	generatedPassword, err := generateCredentials(credentialOptions)
 	if err != nil {
 		return nil, nil, nil, err
@@ -143,32 +273,84 @@ func (u *userBuilder) CreateAccount(
 		return nil, nil, nil, fmt.Errorf("password is required for user creation")
 	}
 	userPayload := client.UserPayload{
-		FirstName:   profile["first_name"].(string),
-		LastName:    profile["last_name"].(string),
-		Email:       profile["email"].(string),
-		Password:    generatedPassword,
-		Designation: "Field Executive",
-		EmpCode:     profile["emp_code"].(string),
-		RoleID:      "3",
+		FirstName:        profile["first_name"].(string),
+		LastName:         profile["last_name"].(string),
+		Email:            profile["email"].(string),
+		Password:         generatedPassword,
+		Designation:      "Field Executive",
+		EmpCode:          profile["emp_code"].(string),
+		RoleID:           "3",
+		AccountExpiresAt: accountExpiresAt,
+	}
+	if class == accountClassContractor {
+		userPayload.UserType = string(accountClassContractor)
 	}
 
 	resp, annos, err := u.client.CreateUser(ctx, userPayload)
 	if err != nil {
 		return nil, nil, annos, fmt.Errorf("failed to create user: %w", err)
 	}
+	// No user was created in a dry run, so there is no resource to return and nothing to assign or roll back. The
+	// result is not a success, which would claim an account that does not exist.
+	if client.IsDryRun(annos) {
+		return &v2.CreateAccountResponse_ActionRequiredResult{
+			Message: fmt.Sprintf("dry run: user %s was not created and no access role or teams were assigned", userPayload.Email),
+		}, nil, annos, nil
+	}
+	userID := resp.Data.UserUID
+
+	provisioning := newSaga("account provisioning for " + userPayload.Email)
+	provisioning.done("create user "+userID, func(ctx context.Context) error {
+		_, _, err := u.client.DeactivateUser(ctx, userID)
+		return err
+	})
+	if accessRoleUID != "" {
+		// Zuper gives new users a default access role, which is what rolling back the assignment restores.
+		created, _, err := u.client.GetUserByID(client.WithoutCache(ctx), userID)
+		if err != nil {
+			return nil, nil, annos, provisioning.rollback(ctx, fmt.Errorf("failed to read created user: %w", err))
+		}
+		var initialRoleUID string
+		if created.AccessRole != nil {
+			initialRoleUID = created.AccessRole.AccessRoleUID
+		}
+		if initialRoleUID != accessRoleUID {
+			if _, _, err := u.client.UpdateUserAccessRole(ctx, userID, accessRoleUID); err != nil {
+				return nil, nil, annos, provisioning.rollback(ctx, fmt.Errorf("failed to assign access role %s: %w", accessRoleUID, err))
+			}
+			// Without an initial role there is nothing to restore, and deactivating the user undoes the assignment.
+			if initialRoleUID != "" {
+				provisioning.done("assign access role "+accessRoleUID, func(ctx context.Context) error {
+					_, _, err := u.client.UpdateUserAccessRole(ctx, userID, initialRoleUID)
+					return err
+				})
+			}
+		}
+	}
+	for _, teamUID := range teamUIDs {
+		if _, _, err := u.client.AssignUserToTeam(ctx, teamUID, userID); err != nil {
+			return nil, nil, annos, provisioning.rollback(ctx, fmt.Errorf("failed to assign team %s: %w", teamUID, err))
+		}
+		provisioning.done("assign team "+teamUID, func(ctx context.Context) error {
+			_, _, err := u.client.UnassignUserFromTeam(ctx, teamUID, userID)
+			return err
+		})
+	}
 
 	newUser := &client.ZuperUser{
-		UserUID:     resp.Data.UserUID,
-		FirstName:   userPayload.FirstName,
-		LastName:    userPayload.LastName,
-		Email:       userPayload.Email,
-		Designation: userPayload.Designation,
-		EmpCode:     userPayload.EmpCode,
-		IsActive:    true,
-		IsDeleted:   false,
+		UserUID:          userID,
+		FirstName:        userPayload.FirstName,
+		LastName:         userPayload.LastName,
+		Email:            userPayload.Email,
+		Designation:      userPayload.Designation,
+		EmpCode:          userPayload.EmpCode,
+		UserType:         userPayload.UserType,
+		AccountExpiresAt: userPayload.AccountExpiresAt,
+		IsActive:         true,
+		IsDeleted:        false,
 	}
 
-	userResource, err := parseIntoUserResource(newUser)
+	userResource, err := parseIntoUserResource(newUser, class)
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("failed to parse created user: %w", err)
 	}
@@ -184,7 +366,7 @@ func (u *userBuilder) CreateAccount(
 }
 
 // parseIntoUserResource converts a ZuperUser into a v2.Resource for Baton.
-func parseIntoUserResource(user *client.ZuperUser) (*v2.Resource, error) {
+func parseIntoUserResource(user *client.ZuperUser, class accountClass) (*v2.Resource, error) {
 	userStatus := v2.UserTrait_Status_STATUS_ENABLED
 	if !user.IsActive {
 		userStatus = v2.UserTrait_Status_STATUS_DISABLED
@@ -194,22 +376,61 @@ func parseIntoUserResource(user *client.ZuperUser) (*v2.Resource, error) {
 	}
 
 	profile := map[string]interface{}{
-		"FirstName":   user.FirstName,
-		"LastName":    user.LastName,
-		"Email":       user.Email,
-		"Designation": user.Designation,
-		"IsActive":    user.IsActive,
-		"IsDeleted":   user.IsDeleted,
-		"EmpCode":     user.EmpCode,
-		"CreatedAt":   user.CreatedAt,
-		"UpdatedAt":   user.UpdatedAt,
+		"FirstName":         user.FirstName,
+		"LastName":          user.LastName,
+		"Email":             user.Email,
+		"Designation":       user.Designation,
+		"IsActive":          user.IsActive,
+		"IsDeleted":         user.IsDeleted,
+		"EmpCode":           user.EmpCode,
+		"ExternalLoginID":   user.ExternalLoginID,
+		"Prefix":            user.Prefix,
+		"HomePhoneNumber":   user.HomePhoneNumber,
+		"WorkPhoneNumber":   user.WorkPhoneNumber,
+		"MobilePhoneNumber": user.MobilePhoneNumber,
+		"CreatedAt":         user.CreatedAt,
+		"UpdatedAt":         user.UpdatedAt,
+		"LastLoginAt":       user.LastLoginAt,
+		"UserType":          user.UserType,
+		"AccountClass":      string(class),
+		"AccountExpiresAt":  user.AccountExpiresAt,
+		// Expired accounts stay active until the expiry sweep deactivates them.
+		"AccountExpired": isExpired(user, time.Now()),
+	}
+
+	// External login and employee code are exposed as login aliases for identity matching.
+	var loginAliases []string
+	for _, alias := range []string{user.ExternalLoginID, user.EmpCode} {
+		if alias != "" && alias != user.Email {
+			loginAliases = append(loginAliases, alias)
+		}
 	}
 
 	userTraits := []resource.UserTraitOption{
 		resource.WithUserProfile(profile),
 		resource.WithStatus(userStatus),
-		resource.WithUserLogin(user.Email),
+		resource.WithUserLogin(user.Email, loginAliases...),
 		resource.WithEmail(user.Email, true),
+		// The SDK has no contractor account type, so contractors remain human accounts and
+		// their class is carried in the AccountClass profile attribute.
+		resource.WithAccountType(v2.UserTrait_ACCOUNT_TYPE_HUMAN),
+		resource.WithStructuredName(&v2.UserTrait_StructuredName{
+			GivenName:  user.FirstName,
+			FamilyName: user.LastName,
+			Prefix:     user.Prefix,
+		}),
+	}
+	if user.EmpCode != "" {
+		userTraits = append(userTraits, resource.WithEmployeeID(user.EmpCode))
+	}
+	if user.ProfilePicture != "" {
+		userTraits = append(userTraits, resource.WithUserIcon(&v2.AssetRef{Id: user.ProfilePicture}))
+	}
+	if createdAt, ok := parseZuperTime(user.CreatedAt); ok {
+		userTraits = append(userTraits, resource.WithCreatedAt(createdAt))
+	}
+	if lastLogin, ok := parseZuperTime(user.LastLoginAt); ok {
+		userTraits = append(userTraits, resource.WithLastLogin(lastLogin))
 	}
 
 	displayName := fmt.Sprintf("%s %s", user.FirstName, user.LastName)
@@ -257,9 +478,12 @@ func makeAccessRoleSubjectID(accessRoleUID string, user *client.ZuperUser) *v2.R
 }
 
 // newUserBuilder creates a new userBuilder instance.
-func newUserBuilder(client UserClient) *userBuilder {
+func newUserBuilder(client UserClient, classifier *accountClassifier, accessRoles *accessRoleBuilder, sweep bool) *userBuilder {
 	return &userBuilder{
 		resourceType: userResourceType,
 		client:       client,
+		classifier:   classifier,
+		accessRoles:  accessRoles,
+		sweep:        sweep,
 	}
 }
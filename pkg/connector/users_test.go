@@ -4,15 +4,21 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
 	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
 	"github.com/conductorone/baton-zuper/pkg/client"
 	"github.com/conductorone/baton-zuper/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 // It validates correct parsing of user data, handling of pagination tokens, annotations, and error scenarios.
@@ -112,3 +118,402 @@ func TestUserBuilder_List(t *testing.T) {
 		})
 	}
 }
+
+// TestParseIntoUserResource validates that timestamps and alternate logins are mapped into the user trait.
+func TestParseIntoUserResource(t *testing.T) {
+	user := &client.ZuperUser{
+		UserUID:         "user-1",
+		FirstName:       "Ramon",
+		LastName:        "Mendoza",
+		Email:           "ramon@example.com",
+		EmpCode:         "0098",
+		ExternalLoginID: "rmendoza",
+		IsActive:        true,
+		CreatedAt:       "2022-12-16T16:32:47.000Z",
+		LastLoginAt:     "2025-05-15T22:33:03.000Z",
+	}
+
+	res, err := parseIntoUserResource(user, accountClassEmployee)
+	require.NoError(t, err)
+
+	trait, err := resource.GetUserTrait(res)
+	require.NoError(t, err)
+	assert.Equal(t, "ramon@example.com", trait.GetLogin())
+	assert.ElementsMatch(t, []string{"rmendoza", "0098"}, trait.GetLoginAliases())
+	assert.Equal(t, []string{"0098"}, trait.GetEmployeeIds())
+	assert.Equal(t, v2.UserTrait_ACCOUNT_TYPE_HUMAN, trait.GetAccountType())
+	assert.Equal(t, "2022-12-16T16:32:47Z", trait.GetCreatedAt().AsTime().Format(time.RFC3339))
+	assert.Equal(t, "2025-05-15T22:33:03Z", trait.GetLastLogin().AsTime().Format(time.RFC3339))
+
+	t.Run("missing timestamps are left unset", func(t *testing.T) {
+		res, err := parseIntoUserResource(&client.ZuperUser{UserUID: "user-2", Email: "a@example.com"}, accountClassEmployee)
+		require.NoError(t, err)
+		trait, err := resource.GetUserTrait(res)
+		require.NoError(t, err)
+		assert.Nil(t, trait.GetCreatedAt())
+		assert.Nil(t, trait.GetLastLogin())
+		assert.Empty(t, trait.GetLoginAliases())
+	})
+}
+
+// TestUserBuilder_CreateAccountContractor validates contractor account creation with an expiry date.
+func TestUserBuilder_CreateAccountContractor(t *testing.T) {
+	var sent client.UserPayload
+	mockCli := &test.MockClient{
+		CreateUserFunc: func(ctx context.Context, user client.UserPayload) (*client.CreateUserResponse, annotations.Annotations, error) {
+			sent = user
+			resp := &client.CreateUserResponse{}
+			resp.Data.UserUID = "new-user"
+			return resp, nil, nil
+		},
+	}
+	builder := newUserBuilder(mockCli, nil, nil, true)
+	credentials := &v2.CredentialOptions{
+		Options: &v2.CredentialOptions_RandomPassword_{RandomPassword: &v2.CredentialOptions_RandomPassword{Length: 16}},
+	}
+	newAccountInfo := func(fields map[string]interface{}) *v2.AccountInfo {
+		profile := map[string]interface{}{
+			"first_name": "Ana",
+			"last_name":  "Lopez",
+			"email":      "ana@vendor.example",
+			"emp_code":   "C-001",
+		}
+		for k, v := range fields {
+			profile[k] = v
+		}
+		pb, err := structpb.NewStruct(profile)
+		require.NoError(t, err)
+		return &v2.AccountInfo{Profile: pb}
+	}
+
+	t.Run("contractor with expiry", func(t *testing.T) {
+		expiry := time.Now().Add(48 * time.Hour).UTC().Format(time.DateOnly)
+		resp, _, _, err := builder.CreateAccount(context.Background(), newAccountInfo(map[string]interface{}{
+			"account_class": "contractor",
+			"expires_at":    expiry,
+		}), credentials)
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		assert.Equal(t, "contractor", sent.UserType)
+		assert.Equal(t, expiry+"T00:00:00Z", sent.AccountExpiresAt)
+	})
+
+	t.Run("expiry requires contractor", func(t *testing.T) {
+		_, _, _, err := builder.CreateAccount(context.Background(), newAccountInfo(map[string]interface{}{
+			"expires_at": "2999-01-01",
+		}), credentials)
+		assert.Error(t, err)
+	})
+
+	t.Run("expiry in the past", func(t *testing.T) {
+		_, _, _, err := builder.CreateAccount(context.Background(), newAccountInfo(map[string]interface{}{
+			"account_class": "contractor",
+			"expires_at":    "2000-01-01",
+		}), credentials)
+		assert.Error(t, err)
+	})
+
+	t.Run("expiry requires the sweep", func(t *testing.T) {
+		sent = client.UserPayload{}
+		unswept := newUserBuilder(mockCli, nil, nil, false)
+		_, _, _, err := unswept.CreateAccount(context.Background(), newAccountInfo(map[string]interface{}{
+			"account_class": "contractor",
+			"expires_at":    "2999-01-01",
+		}), credentials)
+		assert.ErrorContains(t, err, "expiry-sweep")
+		assert.Empty(t, sent.Email, "no user is created")
+	})
+}
+
+// TestUserBuilder_ListReportsExpired tests that listing users reports expired accounts without deactivating them.
+func TestUserBuilder_ListReportsExpired(t *testing.T) {
+	var deactivated []string
+	mockCli := &test.MockClient{
+		GetUsersFunc: func(ctx context.Context, options client.PageOptions) ([]*client.ZuperUser, string, annotations.Annotations, error) {
+			return []*client.ZuperUser{
+				{UserUID: "expired", IsActive: true, AccountExpiresAt: "2000-01-01T00:00:00Z"},
+				{UserUID: "current", IsActive: true, AccountExpiresAt: "2999-01-01T00:00:00Z"},
+			}, "", nil, nil
+		},
+		DeactivateUserFunc: func(ctx context.Context, userUID string) (*client.UpdateUserRoleResponse, annotations.Annotations, error) {
+			deactivated = append(deactivated, userUID)
+			return &client.UpdateUserRoleResponse{}, nil, nil
+		},
+	}
+	builder := newUserBuilder(mockCli, nil, nil, false)
+
+	resources, _, _, err := builder.List(context.Background(), nil, &pagination.Token{})
+	require.NoError(t, err)
+	require.Len(t, resources, 2)
+	assert.Empty(t, deactivated)
+
+	trait, err := resource.GetUserTrait(resources[0])
+	require.NoError(t, err)
+	assert.Equal(t, v2.UserTrait_Status_STATUS_ENABLED, trait.GetStatus().GetStatus())
+	assert.True(t, trait.GetProfile().GetFields()["AccountExpired"].GetBoolValue())
+	trait, err = resource.GetUserTrait(resources[1])
+	require.NoError(t, err)
+	assert.False(t, trait.GetProfile().GetFields()["AccountExpired"].GetBoolValue())
+}
+
+// TestUserBuilder_DeactivateExpiredUsers tests that the expiry sweep deactivates expired accounts only, carrying on
+// past the ones it fails to deactivate.
+func TestUserBuilder_DeactivateExpiredUsers(t *testing.T) {
+	var deactivated []string
+	mockCli := &test.MockClient{
+		GetUsersFunc: func(ctx context.Context, options client.PageOptions) ([]*client.ZuperUser, string, annotations.Annotations, error) {
+			return []*client.ZuperUser{
+				{UserUID: "failing", IsActive: true, AccountExpiresAt: "2000-01-01T00:00:00Z"},
+				{UserUID: "expired", IsActive: true, AccountExpiresAt: "2000-01-01T00:00:00Z"},
+				{UserUID: "inactive", IsActive: false, AccountExpiresAt: "2000-01-01T00:00:00Z"},
+				{UserUID: "current", IsActive: true, AccountExpiresAt: "2999-01-01T00:00:00Z"},
+				{UserUID: "permanent", IsActive: true},
+			}, "", nil, nil
+		},
+		DeactivateUserFunc: func(ctx context.Context, userUID string) (*client.UpdateUserRoleResponse, annotations.Annotations, error) {
+			if userUID == "failing" {
+				return nil, nil, errors.New("boom")
+			}
+			deactivated = append(deactivated, userUID)
+			return &client.UpdateUserRoleResponse{}, nil, nil
+		},
+	}
+	builder := newUserBuilder(mockCli, nil, nil, false)
+
+	err := builder.deactivateExpiredUsers(context.Background(), time.Now())
+	assert.ErrorContains(t, err, "failing")
+	assert.Equal(t, []string{"expired"}, deactivated)
+}
+
+// TestUserBuilder_GrantsIncompleteRoles tests that roles missing their key or UID are resolved by name, and that
+// roles which cannot be resolved produce no grant instead of one with an empty resource ID.
+func TestUserBuilder_GrantsIncompleteRoles(t *testing.T) {
+	users := map[string]*client.ZuperUser{
+		"named": {
+			UserUID:    "named",
+			Role:       &client.Role{RoleName: "field executive"},
+			AccessRole: &client.AccessRole{AccessRoleName: "Zuper Manager"},
+		},
+		"unknown": {
+			UserUID:    "unknown",
+			Role:       &client.Role{RoleName: "Contractor"},
+			AccessRole: &client.AccessRole{AccessRoleName: "Nobody"},
+		},
+		"manager": {
+			UserUID:    "manager",
+			Role:       &client.Role{RoleUID: "2", RoleKey: "TEAM_LEADER"},
+			AccessRole: &client.AccessRole{AccessRoleUID: "ar-manager", AccessRoleName: "zuper manager"},
+		},
+	}
+	mockCli := &test.MockClient{
+		GetUsersFunc: func(ctx context.Context, options client.PageOptions) ([]*client.ZuperUser, string, annotations.Annotations, error) {
+			return []*client.ZuperUser{users["named"], users["unknown"], users["manager"]}, "", nil, nil
+		},
+		GetUserByIDFunc: func(ctx context.Context, userUID string) (*client.ZuperUser, annotations.Annotations, error) {
+			return users[userUID], nil, nil
+		},
+	}
+	builder := newUserBuilder(mockCli, nil, newAccessRoleBuilder(mockCli, nil, nil), false)
+
+	targets := func(userUID string) []string {
+		principal, err := resource.NewUserResource(userUID, userResourceType, userUID, nil)
+		require.NoError(t, err)
+		grants, _, _, err := builder.Grants(context.Background(), principal, &pagination.Token{})
+		require.NoError(t, err)
+		var ids []string
+		for _, g := range grants {
+			id := g.GetEntitlement().GetResource().GetId()
+			require.NotEmpty(t, id.GetResource())
+			ids = append(ids, id.GetResourceType()+":"+id.GetResource())
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{"role:FIELD_EXECUTIVE", "access-role:ar-manager"}, targets("named"))
+	assert.Empty(t, targets("unknown"))
+	assert.Equal(t, []string{"role:TEAM_LEADER", "access-role:ar-manager"}, targets("manager"))
+}
+
+// TestUserBuilder_CreateAccountRollback tests that a failed provisioning step undoes the completed ones.
+func TestUserBuilder_CreateAccountRollback(t *testing.T) {
+	var calls []string
+	mockCli := &test.MockClient{
+		CreateUserFunc: func(ctx context.Context, user client.UserPayload) (*client.CreateUserResponse, annotations.Annotations, error) {
+			resp := &client.CreateUserResponse{}
+			resp.Data.UserUID = "new-user"
+			return resp, nil, nil
+		},
+		GetUserByIDFunc: func(ctx context.Context, userUID string) (*client.ZuperUser, annotations.Annotations, error) {
+			return &client.ZuperUser{UserUID: userUID, AccessRole: &client.AccessRole{AccessRoleUID: "ar-default"}}, nil, nil
+		},
+		UpdateUserAccessRoleFunc: func(ctx context.Context, userUID string, accessRoleUID string) (*client.UpdateUserRoleResponse, annotations.Annotations, error) {
+			calls = append(calls, "access_role="+accessRoleUID)
+			return &client.UpdateUserRoleResponse{}, nil, nil
+		},
+		AssignUserToTeamFunc: func(ctx context.Context, teamUID, userUID string) (*client.AssignUserToTeamResponse, annotations.Annotations, error) {
+			if teamUID == "team-2" {
+				return nil, nil, errors.New("team not found")
+			}
+			calls = append(calls, "assign "+teamUID)
+			return &client.AssignUserToTeamResponse{}, nil, nil
+		},
+		UnassignUserFromTeamFunc: func(ctx context.Context, teamUID, userUID string) (*client.AssignUserToTeamResponse, annotations.Annotations, error) {
+			calls = append(calls, "unassign "+teamUID)
+			return &client.AssignUserToTeamResponse{}, nil, nil
+		},
+		DeactivateUserFunc: func(ctx context.Context, userUID string) (*client.UpdateUserRoleResponse, annotations.Annotations, error) {
+			return nil, nil, errors.New("deactivation failed")
+		},
+	}
+	builder := newUserBuilder(mockCli, nil, nil, false)
+	profile, err := structpb.NewStruct(map[string]interface{}{
+		"first_name":  "Ana",
+		"last_name":   "Lopez",
+		"email":       "ana@example.com",
+		"emp_code":    "E-001",
+		"access_role": "technician",
+		"teams":       []interface{}{"team-1", "team-2"},
+	})
+	require.NoError(t, err)
+	credentials := &v2.CredentialOptions{
+		Options: &v2.CredentialOptions_RandomPassword_{RandomPassword: &v2.CredentialOptions_RandomPassword{Length: 16}},
+	}
+
+	resp, _, _, err := builder.CreateAccount(context.Background(), &v2.AccountInfo{Profile: profile}, credentials)
+	require.Error(t, err)
+	assert.Nil(t, resp)
+	assert.Equal(t, []string{"access_role=technician", "assign team-1", "unassign team-1", "access_role=ar-default"}, calls)
+	assert.Contains(t, err.Error(), "team not found")
+	assert.Contains(t, err.Error(), "rolled back: assign team team-1, assign access role technician")
+	assert.Contains(t, err.Error(), "could not roll back: create user new-user (deactivation failed)")
+}
+
+// TestUserBuilder_CreateAccountRollbackRoleRestoreFails tests that failing to restore the initial access role does not
+// stop the rest of the rollback.
+func TestUserBuilder_CreateAccountRollbackRoleRestoreFails(t *testing.T) {
+	var calls []string
+	mockCli := &test.MockClient{
+		CreateUserFunc: func(ctx context.Context, user client.UserPayload) (*client.CreateUserResponse, annotations.Annotations, error) {
+			resp := &client.CreateUserResponse{}
+			resp.Data.UserUID = "new-user"
+			return resp, nil, nil
+		},
+		GetUserByIDFunc: func(ctx context.Context, userUID string) (*client.ZuperUser, annotations.Annotations, error) {
+			return &client.ZuperUser{UserUID: userUID, AccessRole: &client.AccessRole{AccessRoleUID: "ar-default"}}, nil, nil
+		},
+		UpdateUserAccessRoleFunc: func(ctx context.Context, userUID string, accessRoleUID string) (*client.UpdateUserRoleResponse, annotations.Annotations, error) {
+			calls = append(calls, "access_role="+accessRoleUID)
+			if accessRoleUID == "ar-default" {
+				return nil, nil, errors.New("role update failed")
+			}
+			return &client.UpdateUserRoleResponse{}, nil, nil
+		},
+		AssignUserToTeamFunc: func(ctx context.Context, teamUID, userUID string) (*client.AssignUserToTeamResponse, annotations.Annotations, error) {
+			return nil, nil, errors.New("team not found")
+		},
+		DeactivateUserFunc: func(ctx context.Context, userUID string) (*client.UpdateUserRoleResponse, annotations.Annotations, error) {
+			calls = append(calls, "deactivate "+userUID)
+			return &client.UpdateUserRoleResponse{}, nil, nil
+		},
+	}
+	builder := newUserBuilder(mockCli, nil, nil, false)
+	profile, err := structpb.NewStruct(map[string]interface{}{
+		"first_name":  "Ana",
+		"last_name":   "Lopez",
+		"email":       "ana@example.com",
+		"emp_code":    "E-001",
+		"access_role": "technician",
+		"teams":       []interface{}{"team-1"},
+	})
+	require.NoError(t, err)
+	credentials := &v2.CredentialOptions{
+		Options: &v2.CredentialOptions_RandomPassword_{RandomPassword: &v2.CredentialOptions_RandomPassword{Length: 16}},
+	}
+
+	_, _, _, err = builder.CreateAccount(context.Background(), &v2.AccountInfo{Profile: profile}, credentials)
+	require.Error(t, err)
+	assert.Equal(t, []string{"access_role=technician", "access_role=ar-default", "deactivate new-user"}, calls)
+	assert.Contains(t, err.Error(), "rolled back: create user new-user")
+	assert.Contains(t, err.Error(), "could not roll back: assign access role technician (role update failed)")
+}
+
+// TestUserBuilder_CreateAccountDryRun tests that a dry run stops after the simulated user creation.
+func TestUserBuilder_CreateAccountDryRun(t *testing.T) {
+	var calls []string
+	dryRun, err := structpb.NewStruct(map[string]interface{}{"dry_run": true, "method": "POST"})
+	require.NoError(t, err)
+	mockCli := &test.MockClient{
+		CreateUserFunc: func(ctx context.Context, user client.UserPayload) (*client.CreateUserResponse, annotations.Annotations, error) {
+			return &client.CreateUserResponse{}, annotations.New(dryRun), nil
+		},
+		UpdateUserAccessRoleFunc: func(ctx context.Context, userUID string, accessRoleUID string) (*client.UpdateUserRoleResponse, annotations.Annotations, error) {
+			calls = append(calls, "access_role="+accessRoleUID)
+			return &client.UpdateUserRoleResponse{}, nil, nil
+		},
+		AssignUserToTeamFunc: func(ctx context.Context, teamUID, userUID string) (*client.AssignUserToTeamResponse, annotations.Annotations, error) {
+			calls = append(calls, "assign "+teamUID)
+			return &client.AssignUserToTeamResponse{}, nil, nil
+		},
+	}
+	builder := newUserBuilder(mockCli, nil, nil, false)
+	profile, err := structpb.NewStruct(map[string]interface{}{
+		"first_name":  "Ana",
+		"last_name":   "Lopez",
+		"email":       "ana@example.com",
+		"emp_code":    "E-001",
+		"access_role": "technician",
+		"teams":       []interface{}{"team-1"},
+	})
+	require.NoError(t, err)
+	credentials := &v2.CredentialOptions{
+		Options: &v2.CredentialOptions_RandomPassword_{RandomPassword: &v2.CredentialOptions_RandomPassword{Length: 16}},
+	}
+
+	resp, plaintexts, annos, err := builder.CreateAccount(context.Background(), &v2.AccountInfo{Profile: profile}, credentials)
+	require.NoError(t, err)
+	result, ok := resp.(*v2.CreateAccountResponse_ActionRequiredResult)
+	require.True(t, ok)
+	assert.Nil(t, result.Resource)
+	assert.Contains(t, result.Message, "dry run")
+	assert.Empty(t, plaintexts)
+	assert.True(t, client.IsDryRun(annos))
+	assert.Empty(t, calls)
+}
+
+// TestConnector_CreateAccountDryRun tests that the SDK reports a dry-run account creation as requiring action rather
+// than as a created account, and passes the dry-run annotation on.
+func TestConnector_CreateAccountDryRun(t *testing.T) {
+	ctx := context.Background()
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	conn, err := New(ctx, server.URL, "key", WithDryRun(true))
+	require.NoError(t, err)
+	connectorServer, err := connectorbuilder.NewConnector(ctx, conn)
+	require.NoError(t, err)
+	profile, err := structpb.NewStruct(map[string]interface{}{
+		"first_name": "Ana",
+		"last_name":  "Lopez",
+		"email":      "ana@example.com",
+		"emp_code":   "E-001",
+	})
+	require.NoError(t, err)
+
+	resp, err := connectorServer.CreateAccount(ctx, &v2.CreateAccountRequest{
+		AccountInfo: &v2.AccountInfo{Profile: profile},
+		CredentialOptions: &v2.CredentialOptions{
+			Options: &v2.CredentialOptions_RandomPassword_{RandomPassword: &v2.CredentialOptions_RandomPassword{Length: 16}},
+		},
+	})
+	require.NoError(t, err)
+	assert.Nil(t, resp.GetSuccess())
+	require.NotNil(t, resp.GetActionRequired())
+	assert.Nil(t, resp.GetActionRequired().GetResource())
+	assert.Empty(t, resp.GetEncryptedData())
+	assert.True(t, client.IsDryRun(resp.GetAnnotations()))
+	assert.Empty(t, requests)
+}
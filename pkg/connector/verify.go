@@ -0,0 +1,65 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/conductorone/baton-zuper/pkg/client"
+)
+
+// defaultVerifyInterval is how often a change is re-read while waiting for it to show up in Zuper.
+const defaultVerifyInterval = time.Second
+
+// ErrNotConverged is returned when a change Zuper accepted never shows up when read back.
+var ErrNotConverged = errors.New("change did not converge in Zuper")
+
+// writeVerifier re-reads Zuper after a change until it reflects the change or a timeout passes.
+// A nil writeVerifier skips verification.
+type writeVerifier struct {
+	timeout  time.Duration
+	interval time.Duration
+}
+
+// newWriteVerifier returns a writeVerifier waiting up to timeout, or nil when timeout is not positive.
+func newWriteVerifier(timeout time.Duration) *writeVerifier {
+	if timeout <= 0 {
+		return nil
+	}
+	return &writeVerifier{
+		timeout:  timeout,
+		interval: defaultVerifyInterval,
+	}
+}
+
+// await polls converged until it reports true. It returns an error wrapping ErrNotConverged if the
+// timeout passes first, describing the expected state with description.
+func (v *writeVerifier) await(ctx context.Context, description string, converged func(ctx context.Context) (bool, error)) error {
+	if v == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(client.WithoutCache(ctx), v.timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(v.interval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		ok, err := converged(ctx)
+		if err == nil && ok {
+			return nil
+		}
+		lastErr = err
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("%w after %s: %s: %w", ErrNotConverged, v.timeout, description, lastErr)
+			}
+			return fmt.Errorf("%w after %s: %s", ErrNotConverged, v.timeout, description)
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-zuper/pkg/client"
+	"github.com/conductorone/baton-zuper/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestVerifier returns a writeVerifier that polls quickly.
+func newTestVerifier(timeout time.Duration) *writeVerifier {
+	return &writeVerifier{timeout: timeout, interval: time.Millisecond}
+}
+
+// TestWriteVerifier_Await tests polling until a change converges or the timeout passes.
+func TestWriteVerifier_Await(t *testing.T) {
+	t.Run("converges after a few reads", func(t *testing.T) {
+		reads := 0
+		err := newTestVerifier(time.Second).await(context.Background(), "never", func(ctx context.Context) (bool, error) {
+			reads++
+			return reads >= 3, nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, reads)
+	})
+
+	t.Run("times out", func(t *testing.T) {
+		err := newTestVerifier(20*time.Millisecond).await(context.Background(), "user is not a member", func(ctx context.Context) (bool, error) {
+			return false, nil
+		})
+		assert.ErrorIs(t, err, ErrNotConverged)
+		assert.Contains(t, err.Error(), "user is not a member")
+	})
+
+	t.Run("nil verifier skips verification", func(t *testing.T) {
+		var verifier *writeVerifier
+		err := verifier.await(context.Background(), "never", func(ctx context.Context) (bool, error) {
+			return false, errors.New("should not be called")
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, newWriteVerifier(0))
+	})
+}
+
+// TestTeamBuilder_GrantVerification tests that a team grant fails when the membership never shows up.
+func TestTeamBuilder_GrantVerification(t *testing.T) {
+	members := []*client.ZuperUser{}
+	applied := false
+	mockCli := &test.MockClient{
+		AssignUserToTeamFunc: func(ctx context.Context, teamUID, userUID string) (*client.AssignUserToTeamResponse, annotations.Annotations, error) {
+			if applied {
+				members = append(members, &client.ZuperUser{UserUID: userUID})
+			}
+			return &client.AssignUserToTeamResponse{Message: "User assigned to team"}, nil, nil
+		},
+		GetTeamUsersFunc: func(ctx context.Context, teamID string) ([]*client.ZuperUser, string, annotations.Annotations, error) {
+			return members, "", nil, nil
+		},
+	}
+	builder := &teamBuilder{
+		resourceType: teamResourceType,
+		client:       mockCli,
+		verifier:     newTestVerifier(20 * time.Millisecond),
+	}
+	ent := &v2.Entitlement{Resource: &v2.Resource{Id: &v2.ResourceId{ResourceType: teamResourceType.Id, Resource: "team-1"}}}
+	userRes := &v2.Resource{Id: &v2.ResourceId{ResourceType: userResourceType.Id, Resource: "user-1"}}
+
+	_, _, err := builder.Grant(context.Background(), userRes, ent)
+	assert.ErrorIs(t, err, ErrNotConverged)
+
+	applied = true
+	grants, _, err := builder.Grant(context.Background(), userRes, ent)
+	require.NoError(t, err)
+	assert.Len(t, grants, 1)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"iter"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -21,15 +22,32 @@ const (
 
 // MockClient is a mock implementation of the Zuper client for testing.
 type MockClient struct {
-	GetUsersFunc             func(ctx context.Context, options client.PageOptions) ([]*client.ZuperUser, string, annotations.Annotations, error)
-	GetUserByIDFunc          func(ctx context.Context, userUID string) (*client.ZuperUser, annotations.Annotations, error)
-	CreateUserFunc           func(ctx context.Context, user client.UserPayload) (*client.CreateUserResponse, annotations.Annotations, error)
-	GetTeamsFunc             func(ctx context.Context, options client.PageOptions) ([]*client.Team, string, annotations.Annotations, error)
-	GetTeamUsersFunc         func(ctx context.Context, teamID string) ([]*client.ZuperUser, string, annotations.Annotations, error)
-	AssignUserToTeamFunc     func(ctx context.Context, teamUID, userUID string) (*client.AssignUserToTeamResponse, annotations.Annotations, error)
-	UnassignUserFromTeamFunc func(ctx context.Context, teamUID, userUID string) (*client.AssignUserToTeamResponse, annotations.Annotations, error)
-	UpdateUserRoleFunc       func(ctx context.Context, userUID string, roleID int) (*client.UpdateUserRoleResponse, annotations.Annotations, error)
-	UpdateUserAccessRoleFunc func(ctx context.Context, userUID string, accessRoleUID string) (*client.UpdateUserRoleResponse, annotations.Annotations, error)
+	GetUsersFunc                    func(ctx context.Context, options client.PageOptions) ([]*client.ZuperUser, string, annotations.Annotations, error)
+	GetUserByIDFunc                 func(ctx context.Context, userUID string) (*client.ZuperUser, annotations.Annotations, error)
+	CreateUserFunc                  func(ctx context.Context, user client.UserPayload) (*client.CreateUserResponse, annotations.Annotations, error)
+	GetTeamsFunc                    func(ctx context.Context, options client.PageOptions) ([]*client.Team, string, annotations.Annotations, error)
+	GetTeamUsersFunc                func(ctx context.Context, teamID string) ([]*client.ZuperUser, string, annotations.Annotations, error)
+	AssignUserToTeamFunc            func(ctx context.Context, teamUID, userUID string) (*client.AssignUserToTeamResponse, annotations.Annotations, error)
+	UnassignUserFromTeamFunc        func(ctx context.Context, teamUID, userUID string) (*client.AssignUserToTeamResponse, annotations.Annotations, error)
+	UpdateUserRoleFunc              func(ctx context.Context, userUID string, roleID int) (*client.UpdateUserRoleResponse, annotations.Annotations, error)
+	UpdateUserAccessRoleFunc        func(ctx context.Context, userUID string, accessRoleUID string) (*client.UpdateUserRoleResponse, annotations.Annotations, error)
+	UpdateRoleIfUnchangedFunc       func(ctx context.Context, observed *client.ZuperUser, roleID int) (*client.UpdateUserRoleResponse, annotations.Annotations, error)
+	UpdateAccessRoleIfUnchangedFunc func(ctx context.Context, observed *client.ZuperUser, accessRoleUID string) (*client.UpdateUserRoleResponse, annotations.Annotations, error)
+	DeactivateUserFunc              func(ctx context.Context, userUID string) (*client.UpdateUserRoleResponse, annotations.Annotations, error)
+	GetAPIKeysFunc                  func(ctx context.Context, options client.PageOptions) ([]*client.APIKey, string, annotations.Annotations, error)
+	DeleteAPIKeyFunc                func(ctx context.Context, apiKeyUID string) (*client.DeleteAPIKeyResponse, annotations.Annotations, error)
+	GetAccessRolePermsFunc          func(ctx context.Context, accessRoleUID string) ([]*client.ModulePermission, annotations.Annotations, error)
+	GetCustomersFunc                func(ctx context.Context, options client.PageOptions) ([]*client.Customer, string, annotations.Annotations, error)
+	GetPortalUsersFunc              func(ctx context.Context, customerUID string, options client.PageOptions) ([]*client.CustomerPortalUser, string, annotations.Annotations, error)
+	UpdatePortalAccessFunc          func(ctx context.Context, customerUID string, portalUserUID string, enabled bool) (*client.UpdatePortalAccessResponse, annotations.Annotations, error)
+	GetTerritoriesFunc              func(ctx context.Context, options client.PageOptions) ([]*client.Territory, string, annotations.Annotations, error)
+	GetTerritoryUsersFunc           func(ctx context.Context, territoryUID string) ([]*client.ZuperUser, annotations.Annotations, error)
+	AssignTerritoryFunc             func(ctx context.Context, territoryUID, userUID string) (*client.AssignUserToTerritoryResponse, annotations.Annotations, error)
+	UnassignTerritoryFunc           func(ctx context.Context, territoryUID, userUID string) (*client.AssignUserToTerritoryResponse, annotations.Annotations, error)
+	GetSkillsFunc                   func(ctx context.Context, options client.PageOptions) ([]*client.Skill, string, annotations.Annotations, error)
+	GetSkillUsersFunc               func(ctx context.Context, skillUID string) ([]*client.UserSkill, annotations.Annotations, error)
+	AssignSkillFunc                 func(ctx context.Context, skillUID, userUID, expiresAt string) (*client.AssignSkillToUserResponse, annotations.Annotations, error)
+	UnassignSkillFunc               func(ctx context.Context, skillUID, userUID string) (*client.AssignSkillToUserResponse, annotations.Annotations, error)
 }
 
 // GetUsers calls the mock method if it is defined.
@@ -40,6 +58,43 @@ func (m *MockClient) GetUsers(ctx context.Context, options client.PageOptions) (
 	return nil, "", nil, nil
 }
 
+// EachUser hands the users returned by the GetUsers mock to fn one at a time.
+func (m *MockClient) EachUser(ctx context.Context, options client.PageOptions, fn func(*client.ZuperUser) error) (string, annotations.Annotations, error) {
+	users, nextToken, annos, err := m.GetUsers(ctx, options)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, user := range users {
+		if err := fn(user); err != nil {
+			return "", nil, err
+		}
+	}
+	return nextToken, annos, nil
+}
+
+// AllUsers iterates over the users returned by the GetUsers mock, following page tokens.
+func (m *MockClient) AllUsers(ctx context.Context, annos *annotations.Annotations) iter.Seq2[*client.ZuperUser, error] {
+	return func(yield func(*client.ZuperUser, error) bool) {
+		token := ""
+		for {
+			users, nextToken, _, err := m.GetUsers(ctx, client.PageOptions{PageToken: token, PageSize: client.DefaultPageSize})
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			for _, user := range users {
+				if !yield(user, nil) {
+					return
+				}
+			}
+			if nextToken == "" {
+				return
+			}
+			token = nextToken
+		}
+	}
+}
+
 // CreateUser calls the mock method if it is defined.
 func (m *MockClient) CreateUser(ctx context.Context, user client.UserPayload) (*client.CreateUserResponse, annotations.Annotations, error) {
 	if m.CreateUserFunc != nil {
@@ -72,6 +127,38 @@ func (m *MockClient) GetTeamUsers(ctx context.Context, teamID string) ([]*client
 	return nil, "", nil, nil
 }
 
+// AllTeamMembers iterates over the users returned by the GetTeamUsers mock, adding its annotations to annos.
+func (m *MockClient) AllTeamMembers(ctx context.Context, teamID string, annos *annotations.Annotations) iter.Seq2[*client.ZuperUser, error] {
+	return func(yield func(*client.ZuperUser, error) bool) {
+		users, _, teamAnnos, err := m.GetTeamUsers(ctx, teamID)
+		if annos != nil {
+			annos.Merge(teamAnnos...)
+		}
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for _, user := range users {
+			if !yield(user, nil) {
+				return
+			}
+		}
+	}
+}
+
+// IsUserInTeam checks the users returned by the GetTeamUsers mock for the user.
+func (m *MockClient) IsUserInTeam(ctx context.Context, teamUID string, userUID string) (bool, error) {
+	for user, err := range m.AllTeamMembers(ctx, teamUID, nil) {
+		if err != nil {
+			return false, err
+		}
+		if user.UserUID == userUID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // AssignUserToTeam calls the mock method if it is defined.
 func (m *MockClient) AssignUserToTeam(ctx context.Context, teamUID, userUID string) (*client.AssignUserToTeamResponse, annotations.Annotations, error) {
 	if m.AssignUserToTeamFunc != nil {
@@ -105,6 +192,163 @@ func (m *MockClient) UpdateUserAccessRole(ctx context.Context, userUID string, a
 	return nil, nil, nil
 }
 
+// UpdateUserRoleIfUnchanged calls the mock method if it is defined, falling back to UpdateUserRoleFunc.
+func (m *MockClient) UpdateUserRoleIfUnchanged(ctx context.Context, observed *client.ZuperUser, roleID int) (*client.UpdateUserRoleResponse, annotations.Annotations, error) {
+	if m.UpdateRoleIfUnchangedFunc != nil {
+		return m.UpdateRoleIfUnchangedFunc(ctx, observed, roleID)
+	}
+	return m.UpdateUserRole(ctx, observed.UserUID, roleID)
+}
+
+// UpdateUserAccessRoleIfUnchanged calls the mock method if it is defined, falling back to UpdateUserAccessRoleFunc.
+func (m *MockClient) UpdateUserAccessRoleIfUnchanged(ctx context.Context, observed *client.ZuperUser, accessRoleUID string) (*client.UpdateUserRoleResponse, annotations.Annotations, error) {
+	if m.UpdateAccessRoleIfUnchangedFunc != nil {
+		return m.UpdateAccessRoleIfUnchangedFunc(ctx, observed, accessRoleUID)
+	}
+	return m.UpdateUserAccessRole(ctx, observed.UserUID, accessRoleUID)
+}
+
+// GetAPIKeys calls the mock method if it is defined.
+func (m *MockClient) GetAPIKeys(ctx context.Context, options client.PageOptions) ([]*client.APIKey, string, annotations.Annotations, error) {
+	if m.GetAPIKeysFunc != nil {
+		return m.GetAPIKeysFunc(ctx, options)
+	}
+	return nil, "", nil, nil
+}
+
+// DeleteAPIKey calls the mock method if it is defined.
+func (m *MockClient) DeleteAPIKey(ctx context.Context, apiKeyUID string) (*client.DeleteAPIKeyResponse, annotations.Annotations, error) {
+	if m.DeleteAPIKeyFunc != nil {
+		return m.DeleteAPIKeyFunc(ctx, apiKeyUID)
+	}
+	return nil, nil, nil
+}
+
+// GetAccessRolePermissions calls the mock method if it is defined.
+func (m *MockClient) GetAccessRolePermissions(ctx context.Context, accessRoleUID string) ([]*client.ModulePermission, annotations.Annotations, error) {
+	if m.GetAccessRolePermsFunc != nil {
+		return m.GetAccessRolePermsFunc(ctx, accessRoleUID)
+	}
+	return nil, nil, nil
+}
+
+// GetCustomers calls the mock method if it is defined.
+func (m *MockClient) GetCustomers(ctx context.Context, options client.PageOptions) ([]*client.Customer, string, annotations.Annotations, error) {
+	if m.GetCustomersFunc != nil {
+		return m.GetCustomersFunc(ctx, options)
+	}
+	return nil, "", nil, nil
+}
+
+// GetCustomerPortalUsers calls the mock method if it is defined.
+func (m *MockClient) GetCustomerPortalUsers(ctx context.Context, customerUID string, options client.PageOptions) ([]*client.CustomerPortalUser, string, annotations.Annotations, error) {
+	if m.GetPortalUsersFunc != nil {
+		return m.GetPortalUsersFunc(ctx, customerUID, options)
+	}
+	return nil, "", nil, nil
+}
+
+// HasPortalAccess reports the access of the portal user among those returned by the GetPortalUsers mock,
+// following page tokens.
+func (m *MockClient) HasPortalAccess(ctx context.Context, customerUID string, portalUserUID string) (bool, error) {
+	token := ""
+	for {
+		users, nextToken, _, err := m.GetCustomerPortalUsers(ctx, customerUID, client.PageOptions{PageToken: token, PageSize: client.DefaultPageSize})
+		if err != nil {
+			return false, err
+		}
+		for _, user := range users {
+			if user.PortalUserUID == portalUserUID {
+				return user.HasAccess, nil
+			}
+		}
+		if nextToken == "" {
+			return false, nil
+		}
+		token = nextToken
+	}
+}
+
+// UpdateCustomerPortalAccess calls the mock method if it is defined.
+func (m *MockClient) UpdateCustomerPortalAccess(ctx context.Context, customerUID string, portalUserUID string, enabled bool) (*client.UpdatePortalAccessResponse, annotations.Annotations, error) {
+	if m.UpdatePortalAccessFunc != nil {
+		return m.UpdatePortalAccessFunc(ctx, customerUID, portalUserUID, enabled)
+	}
+	return nil, nil, nil
+}
+
+// GetTerritories calls the mock method if it is defined.
+func (m *MockClient) GetTerritories(ctx context.Context, options client.PageOptions) ([]*client.Territory, string, annotations.Annotations, error) {
+	if m.GetTerritoriesFunc != nil {
+		return m.GetTerritoriesFunc(ctx, options)
+	}
+	return nil, "", nil, nil
+}
+
+// GetTerritoryUsers calls the mock method if it is defined.
+func (m *MockClient) GetTerritoryUsers(ctx context.Context, territoryUID string) ([]*client.ZuperUser, annotations.Annotations, error) {
+	if m.GetTerritoryUsersFunc != nil {
+		return m.GetTerritoryUsersFunc(ctx, territoryUID)
+	}
+	return nil, nil, nil
+}
+
+// AssignUserToTerritory calls the mock method if it is defined.
+func (m *MockClient) AssignUserToTerritory(ctx context.Context, territoryUID, userUID string) (*client.AssignUserToTerritoryResponse, annotations.Annotations, error) {
+	if m.AssignTerritoryFunc != nil {
+		return m.AssignTerritoryFunc(ctx, territoryUID, userUID)
+	}
+	return nil, nil, nil
+}
+
+// UnassignUserFromTerritory calls the mock method if it is defined.
+func (m *MockClient) UnassignUserFromTerritory(ctx context.Context, territoryUID, userUID string) (*client.AssignUserToTerritoryResponse, annotations.Annotations, error) {
+	if m.UnassignTerritoryFunc != nil {
+		return m.UnassignTerritoryFunc(ctx, territoryUID, userUID)
+	}
+	return nil, nil, nil
+}
+
+// GetSkills calls the mock method if it is defined.
+func (m *MockClient) GetSkills(ctx context.Context, options client.PageOptions) ([]*client.Skill, string, annotations.Annotations, error) {
+	if m.GetSkillsFunc != nil {
+		return m.GetSkillsFunc(ctx, options)
+	}
+	return nil, "", nil, nil
+}
+
+// GetSkillUsers calls the mock method if it is defined.
+func (m *MockClient) GetSkillUsers(ctx context.Context, skillUID string) ([]*client.UserSkill, annotations.Annotations, error) {
+	if m.GetSkillUsersFunc != nil {
+		return m.GetSkillUsersFunc(ctx, skillUID)
+	}
+	return nil, nil, nil
+}
+
+// AssignSkillToUser calls the mock method if it is defined.
+func (m *MockClient) AssignSkillToUser(ctx context.Context, skillUID, userUID, expiresAt string) (*client.AssignSkillToUserResponse, annotations.Annotations, error) {
+	if m.AssignSkillFunc != nil {
+		return m.AssignSkillFunc(ctx, skillUID, userUID, expiresAt)
+	}
+	return nil, nil, nil
+}
+
+// UnassignSkillFromUser calls the mock method if it is defined.
+func (m *MockClient) UnassignSkillFromUser(ctx context.Context, skillUID, userUID string) (*client.AssignSkillToUserResponse, annotations.Annotations, error) {
+	if m.UnassignSkillFunc != nil {
+		return m.UnassignSkillFunc(ctx, skillUID, userUID)
+	}
+	return nil, nil, nil
+}
+
+// DeactivateUser calls the mock method if it is defined.
+func (m *MockClient) DeactivateUser(ctx context.Context, userUID string) (*client.UpdateUserRoleResponse, annotations.Annotations, error) {
+	if m.DeactivateUserFunc != nil {
+		return m.DeactivateUserFunc(ctx, userUID)
+	}
+	return nil, nil, nil
+}
+
 // ReadFile loads content from a JSON file from /test/mock/.
 func ReadFile(fileName string) string {
 	_, filename, _, _ := runtime.Caller(0)